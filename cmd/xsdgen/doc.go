@@ -4,7 +4,7 @@ associated methods based on one or more XML Schema.
 
 Usage:
 
-	xsdgen [-o file] [-ns xmlns] [-pkg name] [-r rule] file ...
+	xsdgen [-config file] [-o file] [-f] [-catalog file] [-ns xmlns] [-pkg name] [-pkgmap xmlns=path] [-d dir] [-import xmlns=path] [-manifest] [-map {xmlns}name=path.Type] [-r rule] [-choice] [-strictenums] [-validate] [-bignum] [-preservetime] [-groups] [-subst] [-derived] [-nillable] [-pointers] [-roots] file ...
 
 Given a set of XML files containing <xsd:schema> declarations,
 xsdgen will create a new self-contained Go source file containing
@@ -18,8 +18,63 @@ be used more than once. If -ns is not specified, types for all schema in
 all files will be declared.
 
 The default package name and output file are "ws" and "xsdgen_output.go",
-and can be overridden by the -pkg and -o flags, respectively. The xsdgen
-command will try to fetch any schema dependencies before parsing.
+and can be overridden by the -pkg and -o flags, respectively.
+
+For large sets of schema, the -pkgmap flag generates a Go package for
+the types of a namespace instead, with the given import path. The flag
+may be used more than once, and must name every namespace whose types
+are declared. Types that refer to the types of another namespace do so
+through its package, so types shared by several namespaces are declared
+once. Namespaces whose types refer to each other must be given the same
+package. Each package is named after the last element of its import
+path, and is written to the file named by -o in a directory below the
+-d directory (by default, the current directory). The directories
+shared by all import paths are left out, so that
+
+	xsdgen -f -pkgmap urn:common=example.org/ubl/common -pkgmap urn:invoice=example.org/ubl/invoice invoice.xsd
+
+writes the packages to the files common/xsdgen_output.go and
+invoice/xsdgen_output.go.
+
+If the -manifest flag is used, a list of the declared types, keyed by
+namespace and XML name, is written to the file xsdgen_manifest.json in
+the directory of the generated code. The -import flag declares that the
+types of a namespace are declared by an existing package with the given
+import path, such as one generated earlier for a schema that several
+schema import. Its types are referred to through the package, as in
+common.Address, instead of being declared again. If the package has a
+manifest, the names of its types are taken from it, and it is an error
+to refer to a type that the package does not declare. The -import flag
+may be used more than once.
+
+The -map flag declares the values of an XML type with an existing Go
+type, which is not declared again, and whose package is imported by the
+generated code. The XML type is written as {xmlns}name, or as name
+alone for the built-in types of XML Schema, and the Go type as the
+import path of its package followed by the name of the type, such as
+
+	xsdgen -map dateTime=cloud.google.com/go/civil.DateTime \
+		-map '{http://example.org/bank}money=*math/big.Float' bank.xsd
+
+A Go type without an import path is declared in the same package. Types
+derived from a mapped type are held by the same Go type, which must
+implement encoding.TextUnmarshaler and encoding.TextMarshaler, or the
+Unmarshaler and Marshaler interfaces of the encoding/xml package. The
+-map flag may be used more than once.
+
+If the -f flag is used, the schema imported or included by the given
+files are read as well, recursively. A relative schemaLocation is
+resolved against the location of the document containing it, and
+schema at http and https URLs are fetched. Each document is read
+once, so schema that import each other can be used. The components
+of an included document without a targetNamespace are placed in the
+namespace of the schema that includes it.
+
+The -catalog flag names an OASIS XML Catalog file, which maps the
+locations and namespaces of schema to local files. Documents found
+in the catalog are read from the local file instead, and imports
+without a schemaLocation are resolved by namespace. The -catalog
+flag may be used more than once.
 
 The -r flag can be used to specify a series of replacement rules. A replacement
 rule is a string of the form
@@ -33,9 +88,98 @@ For example, the rule
 will transform the identifier Array_Of_soapenc_boolean to booleanArray.
 All identifiers are passed through the defined substitution rules.
 
+By default, each element of a <choice> group is declared as an
+optional struct field. If the -choice flag is used, the alternatives
+of a choice are instead held in a single field, whose type ensures
+that at most one of them is set, and that a required choice is present
+when marshalling and unmarshalling XML.
+
+For each simple type with enumerated values, xsdgen declares a constant
+for each value and an IsValid method. If the -strictenums flag is used,
+values outside of the enumeration are rejected when unmarshalling.
+
+If the -validate flag is used, every generated type has a Validate
+method, which checks the restrictions of simple types, such as their
+patterns, lengths and ranges, and that the required elements and
+attributes of complex types are present.
+
+By default, xs:decimal values are declared as float64, and xs:integer
+values as int. If the -bignum flag is used, decimals are held by the
+generated XSDDecimal type, which preserves them exactly and converts
+to and from *big.Rat, and unbounded integers are declared as *big.Int.
+
+Date and time values are declared as time.Time, and are always written
+with a timezone. If the -preservetime flag is used, they are held by
+generated types such as XSDDateTime, which record whether a timezone
+was present, and write unmodified values exactly as they were read.
+
+The fields of an element or attribute group are declared in every
+type that uses the group. If the -groups flag is used, a struct type
+is declared for each group instead, such as AddressGroup for the
+<group> named "address", and is embedded in the types that use it.
+
+A reference to the head of a substitution group is declared with the
+type of the head element, and cannot hold the elements that may be
+substituted for it. If the -subst flag is used, an interface such as
+ShapeElement is declared for the head element "shape", implemented by
+the types of the elements in its substitution group, and references
+to the head are declared with the interface type.
+
+An element whose type has derived types is declared with the base
+type, and values of the derived types are decoded as the base type.
+If the -derived flag is used, an interface such as AnyAnimal is
+declared for the type "animal", implemented by pointers to it and to
+the types derived from it, and its elements are declared with the
+interface type. The Go type of each value is chosen by the xsi:type
+attribute of its element, which is written when marshalling values of
+a derived type.
+
+A nillable element is declared with the type of its value, and an
+element with xsi:nil="true" is decoded as the zero value. If the
+-nillable flag is used, nillable elements are declared with a wrapper
+type such as NillableString, whose Nil field records the xsi:nil
+attribute. Optional nillable elements are declared as pointers to the
+wrapper, so that absent, nil and empty elements can be distinguished.
+
+Optional elements and attributes are declared with the types of their
+values, so that a zero value such as 0 or false is not marshalled. If
+the -pointers flag is used, optional elements and attributes of simple
+types are declared as pointers, which are nil when they are missing.
+
+A constructor such as NewInvoice is declared for each complex type with
+elements or attributes that have default or fixed values, returning a
+value with those fields set. Attributes with a fixed value are always
+marshalled with that value.
+
+Only types are declared for the elements of a schema. If the -roots
+flag is used, a wrapper type such as Invoice is declared for each
+global element "invoice", with an XMLName field, along with a function
+ParseInvoice that decodes a document from an io.Reader, and a WriteTo
+method that encodes it. The wrapper is named InvoiceDocument if the
+name Invoice is taken, such as by the type of the element, and the
+element is skipped, with a warning, if InvoiceDocument is taken too.
+
 The xsdgen command may be used with the go generate command. Simply
 embed a comment in your go source like so:
 
 	//go:generate xsdgen -ns "http://example.net/ws/" schemafile.xml
+
+If the -config flag is used, options are read from a JSON file, whose
+fields are those of the xsdgen.ConfigFile type. It can set the options
+of the command-line flags, as well as options that have no flag, such
+as IgnoreElements and OnlyTypes:
+
+	{
+		"namespaces": ["http://example.net/ws/"],
+		"packageName": "ws",
+		"replace": ["^ArrayOf -> "],
+		"ignoreElements": ["signature"],
+		"validateMethods": true,
+		"mapTypes": {"dateTime": "cloud.google.com/go/civil.DateTime"}
+	}
+
+Catalogs in the file are relative to it. Flags take precedence over
+the file, and add to its lists and maps. The wsdlgen command reads the
+same file, with the fields of the wsdlgen.ConfigFile type.
 */
 package main // import "aqwari.net/xml/cmd/xsdgen"
@@ -31,19 +31,26 @@ func glob(pat string) string {
 
 func main() {
 	var errorsEncountered bool
-	cfg := new(xsdgen.Config)
 
 	xsdTestCases, err := findXSDTestCases()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	cfg.Option(xsdgen.DefaultOptions...)
 	for _, testCase := range xsdTestCases {
-		code, tests, err := genXSDTests(*cfg, testCase.doc, testCase.pkg)
+		var cfg xsdgen.Config
+		cfg.Option(xsdgen.DefaultOptions...)
+		if testCase.config != nil {
+			opts, err := testCase.config.Options()
+			if err != nil {
+				log.Fatal(testCase.pkg, ": ", err)
+			}
+			cfg.Option(opts...)
+		}
+		code, tests, err := genXSDTests(cfg, testCase)
 		if err != nil {
 			errorsEncountered = true
-			log.Print(testCase.pkg)
+			log.Print(testCase.pkg, ": ", err)
 			continue
 		} else {
 			log.Printf("generated xsd tests for %s", testCase.pkg)
@@ -85,9 +92,12 @@ func writeTestFiles(code, tests *ast.File, pkg string) error {
 //   the document described in the XML schema.
 // - Marshal the resulting file back into an XML document.
 // - Compare the two documents for equality.
+// - Check that each document in the invalid directory, if there
+//   is one, cannot be unmarshalled.
 //
 // Returns type definitions and unit tests as separate files.
-func genXSDTests(cfg xsdgen.Config, data []byte, pkg string) (code, tests *ast.File, err error) {
+func genXSDTests(cfg xsdgen.Config, testCase testCase) (code, tests *ast.File, err error) {
+	data, pkg := testCase.doc, testCase.pkg
 	cfg.Option(xsdgen.PackageName(pkg))
 	main, err := cfg.GenCode(data)
 	if err != nil {
@@ -114,10 +124,12 @@ func genXSDTests(cfg xsdgen.Config, data []byte, pkg string) (code, tests *ast.F
 	doc := topLevelElements(root)
 	fields := make([]ast.Expr, 0, len(doc)*3)
 
+	// Fields are pointers, so that the elements missing from
+	// the sample are not marshalled.
 	for _, elem := range doc {
 		fields = append(fields,
 			gen.Public(elem.Name.Local),
-			ast.NewIdent(main.NameOf(elem.Type)),
+			&ast.StarExpr{X: ast.NewIdent(main.NameOf(elem.Type))},
 			gen.String(fmt.Sprintf(`xml:"%s %s"`, elem.Name.Space, elem.Name.Local)))
 	}
 	expr, err := gen.ToString(gen.Struct(fields...))
@@ -128,9 +140,11 @@ func genXSDTests(cfg xsdgen.Config, data []byte, pkg string) (code, tests *ast.F
 	var params struct {
 		DocStruct string
 		Pkg       string
+		Invalid   bool
 	}
 	params.DocStruct = expr
 	params.Pkg = pkg
+	params.Invalid = testCase.invalid
 	fn, err := gen.Func("Test"+strings.Title(pkg)).
 		Args("t *testing.T").
 		BodyTmpl(`
@@ -173,6 +187,25 @@ func genXSDTests(cfg xsdgen.Config, data []byte, pkg string) (code, tests *ast.F
 					xmltree.MarshalIndent(outputTree, "", "  "),
 					xmltree.MarshalIndent(inputTree, "", "  "))
 			}
+			{{- if .Invalid}}
+
+			invalid, err := filepath.Glob(filepath.Join("invalid", "*.xml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, filename := range invalid {
+				input, err := ioutil.ReadFile(filename)
+				if err != nil {
+					t.Fatal(err)
+				}
+				input = append([]byte("<Document>\n"), input...)
+				input = append(input, []byte("</Document>")...)
+				var document Document
+				if err := xml.Unmarshal(input, &document); err == nil {
+					t.Errorf("%s: unmarshalled an invalid document", filename)
+				}
+			}
+			{{- end}}
 			`, params).Decl()
 
 	if err != nil {
@@ -208,11 +241,20 @@ func topLevelElements(root *xmltree.Element) []Element {
 type testCase struct {
 	pkg string
 	doc []byte
+	// Options of the xsdgen package, if the directory has a
+	// config.json file.
+	config *xsdgen.ConfigFile
+	// Set if the directory has documents that are not valid
+	// and must not be unmarshalled.
+	invalid bool
 }
 
 // Looks for subdirectories containing pairs of (xml, xsd) files
 // that should contain an xml document and the schema it conforms to,
-// respectively. Returns slice of the directory names
+// respectively. A subdirectory may also contain a config.json file,
+// with the fields of an xsdgen.ConfigFile, and an invalid directory
+// of documents that must not be unmarshalled. Returns slice of the
+// directory names
 func findXSDTestCases() ([]testCase, error) {
 	filenames, err := filepath.Glob("*/*.xsd")
 	if err != nil {
@@ -220,14 +262,26 @@ func findXSDTestCases() ([]testCase, error) {
 	}
 	result := make([]testCase, 0, len(filenames))
 	for _, xsdfile := range filenames {
-		if data, err := ioutil.ReadFile(xsdfile); err != nil {
+		data, err := ioutil.ReadFile(xsdfile)
+		if err != nil {
+			return nil, err
+		}
+		dir := filepath.Dir(xsdfile)
+		tc := testCase{
+			pkg: filepath.Base(dir),
+			doc: data,
+		}
+		if _, err := os.Stat(filepath.Join(dir, "config.json")); err == nil {
+			if tc.config, err = xsdgen.ReadConfigFile(filepath.Join(dir, "config.json")); err != nil {
+				return nil, err
+			}
+		}
+		if invalid, err := filepath.Glob(filepath.Join(dir, "invalid", "*.xml")); err != nil {
 			return nil, err
 		} else {
-			result = append(result, testCase{
-				pkg: filepath.Base(filepath.Dir(xsdfile)),
-				doc: data,
-			})
+			tc.invalid = len(invalid) > 0
 		}
+		result = append(result, tc)
 	}
 	return result, nil
 }
@@ -13,7 +13,7 @@ import (
 
 func TestBindata(t *testing.T) {
 	type Document struct {
-		Bindata Bindata `xml:"tns bindata"`
+		Bindata *Bindata `xml:"tns bindata"`
 	}
 	var document Document
 	samples, err := filepath.Glob(filepath.Join("*.xml"))
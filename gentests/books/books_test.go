@@ -13,7 +13,7 @@ import (
 
 func TestBooks(t *testing.T) {
 	type Document struct {
-		Books BooksForm `xml:"urn:books books"`
+		Books *BooksForm `xml:"urn:books books"`
 	}
 	var document Document
 	samples, err := filepath.Glob(filepath.Join("*.xml"))
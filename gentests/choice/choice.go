@@ -0,0 +1,134 @@
+// Code generated by testgen. DO NOT EDIT.
+
+package choice
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+type Contact struct {
+	Name   string        `xml:"urn:contacts name"`
+	Choice ContactChoice `xml:",any"`
+}
+
+func (t *Contact) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type T Contact
+	var overlay struct{ *T }
+	overlay.T = (*T)(t)
+	if err := d.DecodeElement(&overlay, &start); err != nil {
+		return err
+	}
+	if overlay.T.Choice.Which() == "" {
+		return errors.New("Contact must contain one of email, phone, fax")
+	}
+	return nil
+}
+
+// ContactChoice holds one of the elements email, phone, fax of Contact.
+type ContactChoice struct {
+	name  string
+	value interface{}
+}
+
+// Which returns the name of the element held by c, or the
+// empty string if c is empty.
+func (c ContactChoice) Which() string {
+	return c.name
+}
+
+// Email returns the value of the email element, if c holds one.
+func (c ContactChoice) Email() (v string, ok bool) {
+	if c.name == "email" {
+		v, ok = c.value.(string)
+	}
+	return v, ok
+}
+
+// SetEmail replaces the contents of c with the email element.
+func (c *ContactChoice) SetEmail(v string) {
+	c.name, c.value = "email", v
+}
+
+// Phone returns the value of the phone element, if c holds one.
+func (c ContactChoice) Phone() (v string, ok bool) {
+	if c.name == "phone" {
+		v, ok = c.value.(string)
+	}
+	return v, ok
+}
+
+// SetPhone replaces the contents of c with the phone element.
+func (c *ContactChoice) SetPhone(v string) {
+	c.name, c.value = "phone", v
+}
+
+// Fax returns the value of the fax element, if c holds one.
+func (c ContactChoice) Fax() (v string, ok bool) {
+	if c.name == "fax" {
+		v, ok = c.value.(string)
+	}
+	return v, ok
+}
+
+// SetFax replaces the contents of c with the fax element.
+func (c *ContactChoice) SetFax(v string) {
+	c.name, c.value = "fax", v
+}
+func (c *ContactChoice) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	switch {
+	case start.Name.Local == "email" && start.Name.Space == "urn:contacts":
+		if c.name != "" {
+			return fmt.Errorf("Contact may contain only one of email, phone, fax; found %s and %s", c.name, start.Name.Local)
+		}
+		var v string
+		if err := d.DecodeElement(&v, &start); err != nil {
+			return err
+		}
+		c.name, c.value = "email", v
+		return nil
+	case start.Name.Local == "phone" && start.Name.Space == "urn:contacts":
+		if c.name != "" {
+			return fmt.Errorf("Contact may contain only one of email, phone, fax; found %s and %s", c.name, start.Name.Local)
+		}
+		var v string
+		if err := d.DecodeElement(&v, &start); err != nil {
+			return err
+		}
+		c.name, c.value = "phone", v
+		return nil
+	case start.Name.Local == "fax" && start.Name.Space == "urn:contacts":
+		if c.name != "" {
+			return fmt.Errorf("Contact may contain only one of email, phone, fax; found %s and %s", c.name, start.Name.Local)
+		}
+		var v string
+		if err := d.DecodeElement(&v, &start); err != nil {
+			return err
+		}
+		c.name, c.value = "fax", v
+		return nil
+	}
+	return d.Skip()
+}
+func (c ContactChoice) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	switch c.name {
+	case "email":
+		start = xml.StartElement{Name: xml.Name{Space: "urn:contacts", Local: "email"}}
+		v, _ := c.value.(string)
+		return e.EncodeElement(v, start)
+	case "phone":
+		start = xml.StartElement{Name: xml.Name{Space: "urn:contacts", Local: "phone"}}
+		v, _ := c.value.(string)
+		return e.EncodeElement(v, start)
+	case "fax":
+		start = xml.StartElement{Name: xml.Name{Space: "urn:contacts", Local: "fax"}}
+		v, _ := c.value.(string)
+		return e.EncodeElement(v, start)
+	}
+	return errors.New("Contact must contain one of email, phone, fax")
+}
+
+type ContactList struct {
+	Contact []Contact `xml:"urn:contacts contact"`
+}
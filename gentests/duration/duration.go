@@ -0,0 +1,169 @@
+// Code generated by testgen. DO NOT EDIT.
+
+package duration
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Job struct {
+	Name       string       `xml:"urn:jobs name"`
+	Interval   XSDDuration  `xml:"urn:jobs interval"`
+	RetryAfter *XSDDuration `xml:"urn:jobs retryAfter,omitempty"`
+	Timeout    *XSDDuration `xml:"timeout,attr,omitempty"`
+}
+
+type JobList struct {
+	Job []Job `xml:"urn:jobs job"`
+}
+
+// An XSDDuration is a length of time, as represented by the xs:duration
+// type. Years and months are kept separate from the other components,
+// because their length varies. All components are non-negative; the
+// sign of the duration is held by the Negative field. A decoded
+// duration is encoded in the form it was read in, such as P1Y0M,
+// until it is changed. The zero XSDDuration is encoded as PT0S.
+type XSDDuration struct {
+	Negative    bool
+	Years       int
+	Months      int
+	Days        int
+	Hours       int
+	Minutes     int
+	Seconds     int
+	Nanoseconds int
+	lexical     string
+}
+
+func (d *XSDDuration) UnmarshalText(text []byte) error {
+	var v XSDDuration
+	s := string(bytes.TrimSpace(text))
+	if strings.HasPrefix(s, "-") {
+		v.Negative, s = true, s[1:]
+	}
+	if len(s) < 2 || s[0] != 'P' || strings.HasSuffix(s, "T") {
+		return fmt.Errorf("invalid duration %q", text)
+	}
+	units, isTime := "YMD", false
+	for s = s[1:]; s != ""; {
+		if s[0] == 'T' && !isTime {
+			units, isTime, s = "HMS", true, s[1:]
+			continue
+		}
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		})
+		if i <= 0 {
+			return fmt.Errorf("invalid duration %q", text)
+		}
+		num, unit := s[:i], s[i]
+		s = s[i+1:]
+		if j := strings.IndexByte(units, unit); j < 0 {
+			return fmt.Errorf("invalid duration %q", text)
+		} else {
+			units = units[j+1:]
+		}
+		frac := ""
+		if unit == 'S' {
+			if i := strings.IndexByte(num, '.'); i >= 0 {
+				num, frac = num[:i], num[i+1:]
+				if frac == "" || len(frac) > 9 && strings.Trim(frac[9:], "0") != "" {
+					return fmt.Errorf("invalid duration %q", text)
+				}
+			}
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", text)
+		}
+		switch {
+		case unit == 'Y':
+			v.Years = n
+		case unit == 'M' && !isTime:
+			v.Months = n
+		case unit == 'D':
+			v.Days = n
+		case unit == 'H':
+			v.Hours = n
+		case unit == 'M':
+			v.Minutes = n
+		case unit == 'S':
+			v.Seconds = n
+			if frac != "" {
+				frac = (frac + "000000000")[:9]
+				if v.Nanoseconds, err = strconv.Atoi(frac); err != nil {
+					return fmt.Errorf("invalid duration %q", text)
+				}
+			}
+		}
+	}
+	v.lexical = string(text)
+	*d = v
+	return nil
+}
+func (d XSDDuration) MarshalText() ([]byte, error) {
+	if d.lexical != "" {
+		var v XSDDuration
+		if v.UnmarshalText([]byte(d.lexical)) == nil && v == d {
+			return []byte(d.lexical), nil
+		}
+	}
+	var buf bytes.Buffer
+	if d.Negative {
+		buf.WriteByte('-')
+	}
+	buf.WriteByte('P')
+	if d.Years != 0 {
+		fmt.Fprintf(&buf, "%dY", d.Years)
+	}
+	if d.Months != 0 {
+		fmt.Fprintf(&buf, "%dM", d.Months)
+	}
+	if d.Days != 0 {
+		fmt.Fprintf(&buf, "%dD", d.Days)
+	}
+	if d.Hours != 0 || d.Minutes != 0 || d.Seconds != 0 || d.Nanoseconds != 0 {
+		buf.WriteByte('T')
+		if d.Hours != 0 {
+			fmt.Fprintf(&buf, "%dH", d.Hours)
+		}
+		if d.Minutes != 0 {
+			fmt.Fprintf(&buf, "%dM", d.Minutes)
+		}
+		if d.Seconds != 0 || d.Nanoseconds != 0 {
+			buf.WriteString(strconv.Itoa(d.Seconds))
+			if d.Nanoseconds != 0 {
+				frac := fmt.Sprintf("%09d", d.Nanoseconds)
+				buf.WriteString("." + strings.TrimRight(frac, "0"))
+			}
+			buf.WriteByte('S')
+		}
+	}
+	if buf.Bytes()[buf.Len()-1] == 'P' {
+		buf.WriteString("T0S")
+	}
+	return buf.Bytes(), nil
+}
+
+// Duration converts d to a time.Duration, counting a day as 24 hours.
+// It returns false if d has a year or month component, or if d
+// is too long to be represented as a time.Duration.
+func (d XSDDuration) Duration() (time.Duration, bool) {
+	if d.Years != 0 || d.Months != 0 {
+		return 0, false
+	}
+	secs := float64(d.Days)*86400 + float64(d.Hours)*3600 + float64(d.Minutes)*60 + float64(d.Seconds)
+	if secs >= float64(math.MaxInt64/int64(time.Second)) {
+		return 0, false
+	}
+	v := time.Duration(d.Days)*24*time.Hour + time.Duration(d.Hours)*time.Hour + time.Duration(d.Minutes)*time.Minute + time.Duration(d.Seconds)*time.Second + time.Duration(d.Nanoseconds)
+	if d.Negative {
+		v = -v
+	}
+	return v, true
+}
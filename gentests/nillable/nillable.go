@@ -0,0 +1,114 @@
+// Code generated by testgen. DO NOT EDIT.
+
+package nillable
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+type Address struct {
+	City string `xml:"urn:contacts city"`
+}
+
+type Contact struct {
+	Name     string           `xml:"urn:contacts name"`
+	Nickname NillableString   `xml:"urn:contacts nickname"`
+	Phone    []NillableString `xml:"urn:contacts phone"`
+	Address  *NillableAddress `xml:"urn:contacts address,omitempty"`
+	Age      *NillableInt     `xml:"urn:contacts age,omitempty"`
+}
+
+type ContactList struct {
+	Contact []Contact `xml:"urn:contacts contact"`
+}
+
+// NillableAddress holds the value of a nillable element of type Address.
+// Nil is true if the element has the attribute xsi:nil="true", in
+// which case Value is not used.
+type NillableAddress struct {
+	Value Address
+	Nil   bool
+}
+
+func (v *NillableAddress) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*v = NillableAddress{}
+	if _xsiNil(start) {
+		v.Nil = true
+		return d.Skip()
+	}
+	return d.DecodeElement(&v.Value, &start)
+}
+func (v NillableAddress) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if !v.Nil {
+		return e.EncodeElement(&v.Value, start)
+	}
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"}, xml.Attr{Name: xml.Name{Local: "xsi:nil"}, Value: "true"})
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+func _xsiNil(start xml.StartElement) bool {
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "http://www.w3.org/2001/XMLSchema-instance" && attr.Name.Local == "nil" {
+			v := strings.TrimSpace(attr.Value)
+			return v == "true" || v == "1"
+		}
+	}
+	return false
+}
+
+// NillableInt holds the value of a nillable element of type int.
+// Nil is true if the element has the attribute xsi:nil="true", in
+// which case Value is not used.
+type NillableInt struct {
+	Value int
+	Nil   bool
+}
+
+func (v *NillableInt) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*v = NillableInt{}
+	if _xsiNil(start) {
+		v.Nil = true
+		return d.Skip()
+	}
+	return d.DecodeElement(&v.Value, &start)
+}
+func (v NillableInt) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if !v.Nil {
+		return e.EncodeElement(&v.Value, start)
+	}
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"}, xml.Attr{Name: xml.Name{Local: "xsi:nil"}, Value: "true"})
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// NillableString holds the value of a nillable element of type string.
+// Nil is true if the element has the attribute xsi:nil="true", in
+// which case Value is not used.
+type NillableString struct {
+	Value string
+	Nil   bool
+}
+
+func (v *NillableString) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*v = NillableString{}
+	if _xsiNil(start) {
+		v.Nil = true
+		return d.Skip()
+	}
+	return d.DecodeElement(&v.Value, &start)
+}
+func (v NillableString) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if !v.Nil {
+		return e.EncodeElement(&v.Value, start)
+	}
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"}, xml.Attr{Name: xml.Name{Local: "xsi:nil"}, Value: "true"})
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
@@ -0,0 +1,136 @@
+// Code generated by testgen. DO NOT EDIT.
+
+package substitution
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+type Circle struct {
+	Radius float64 `xml:"urn:shapes radius"`
+	Color  string  `xml:"color,attr,omitempty"`
+}
+
+func (Circle) isShapeElement() {
+}
+
+type Drawing struct {
+	Title string         `xml:"urn:shapes title"`
+	Shape []ShapeElement `xml:",any"`
+}
+
+func (t *Drawing) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type T Drawing
+	var layout struct {
+		*T
+		Shape *xsdShapeElementList `xml:",any"`
+	}
+	layout.T = (*T)(t)
+	layout.Shape = &xsdShapeElementList{&layout.T.Shape}
+	return e.EncodeElement(layout, start)
+}
+func (t *Drawing) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type T Drawing
+	var overlay struct {
+		*T
+		Shape *xsdShapeElementList `xml:",any"`
+	}
+	overlay.T = (*T)(t)
+	overlay.Shape = &xsdShapeElementList{&overlay.T.Shape}
+	return d.DecodeElement(&overlay, &start)
+}
+
+type Polygon struct {
+	Side  []float64 `xml:"urn:shapes side"`
+	Color string    `xml:"color,attr,omitempty"`
+}
+
+func (Polygon) isShapeElement() {
+}
+
+type Shape struct {
+	Color string `xml:"color,attr,omitempty"`
+}
+
+// ShapeElement is implemented by the types of the elements that may appear
+// in place of a shape element: circle, polygon, square.
+type ShapeElement interface {
+	isShapeElement()
+}
+
+// A Square holds a square element, which has the same type as
+// other elements of its substitution group.
+type Square struct {
+	Polygon
+}
+
+type xsdShapeElement struct {
+	v *ShapeElement
+}
+
+func (h xsdShapeElement) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	switch {
+	case start.Name.Local == "circle" && start.Name.Space == "urn:shapes":
+		var v Circle
+		if err := d.DecodeElement(&v, &start); err != nil {
+			return err
+		}
+		*h.v = v
+		return nil
+	case start.Name.Local == "polygon" && start.Name.Space == "urn:shapes":
+		var v Polygon
+		if err := d.DecodeElement(&v, &start); err != nil {
+			return err
+		}
+		*h.v = v
+		return nil
+	case start.Name.Local == "square" && start.Name.Space == "urn:shapes":
+		var v Square
+		if err := d.DecodeElement(&v, &start); err != nil {
+			return err
+		}
+		*h.v = v
+		return nil
+	}
+	return d.Skip()
+}
+func (h xsdShapeElement) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	switch v := (*h.v).(type) {
+	case nil:
+		return nil
+	case Circle, *Circle:
+		start = xml.StartElement{Name: xml.Name{Space: "urn:shapes", Local: "circle"}}
+		return e.EncodeElement(v, start)
+	case Polygon, *Polygon:
+		start = xml.StartElement{Name: xml.Name{Space: "urn:shapes", Local: "polygon"}}
+		return e.EncodeElement(v, start)
+	case Square, *Square:
+		start = xml.StartElement{Name: xml.Name{Space: "urn:shapes", Local: "square"}}
+		return e.EncodeElement(v, start)
+	}
+	return fmt.Errorf("cannot marshal %T as a shape element", *h.v)
+}
+
+type xsdShapeElementList struct {
+	v *[]ShapeElement
+}
+
+func (h xsdShapeElementList) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v ShapeElement
+	if err := (xsdShapeElement{&v}).UnmarshalXML(d, start); err != nil {
+		return err
+	}
+	if v != nil {
+		*h.v = append(*h.v, v)
+	}
+	return nil
+}
+func (h xsdShapeElementList) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	for i := range *h.v {
+		if err := (xsdShapeElement{&(*h.v)[i]}).MarshalXML(e, start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,8 +4,12 @@ package commandline // import "aqwari.net/xml/internal/commandline"
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -58,3 +62,60 @@ func (s *Strings) Set(val string) error {
 	*s = append(*s, val)
 	return nil
 }
+
+// The Map type can be used to collect "key=value" pairs from the
+// command line. Keys, such as namespace URIs, may contain "=", so the
+// value is whatever follows the last "=".
+type Map map[string]string
+
+// Keys returns the keys of the Map in sorted order.
+func (m Map) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m Map) String() string {
+	keys := m.Keys()
+	for i, k := range keys {
+		keys[i] = k + "=" + m[k]
+	}
+	return strings.Join(keys, ",")
+}
+
+func (m Map) Set(val string) error {
+	i := strings.LastIndex(val, "=")
+	if i < 1 {
+		return fmt.Errorf("invalid pair %q. must be \"key=value\"", val)
+	}
+	m[val[:i]] = val[i+1:]
+	return nil
+}
+
+// ReadJSON decodes the JSON document in filename into v. Unknown
+// fields are an error, so that misspelt options are not ignored.
+func ReadJSON(filename string, v interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return nil
+}
+
+// RelativePaths makes each relative file name in names, other than
+// URLs, relative to dir instead.
+func RelativePaths(dir string, names []string) {
+	for i, name := range names {
+		if !filepath.IsAbs(name) && !strings.Contains(name, "://") {
+			names[i] = filepath.Join(dir, name)
+		}
+	}
+}
@@ -427,6 +427,12 @@ func TagKey(field *ast.Field, key string) string {
 // FormattedSource converts an abstract syntax tree to
 // formatted Go source code.
 func FormattedSource(file *ast.File, output string) ([]byte, error) {
+	return FormattedFileSource(token.NewFileSet(), file, output)
+}
+
+// FormattedFileSource is like FormattedSource, for a file whose node
+// positions are in fileset, such as one read by the go/parser package.
+func FormattedFileSource(fileset *token.FileSet, file *ast.File, output string) ([]byte, error) {
 	var buf bytes.Buffer
 
 	// TrimSuffix allows *nix and Windows to produce identical output
@@ -434,8 +440,6 @@ func FormattedSource(file *ast.File, output string) ([]byte, error) {
 	generatedByComment := fmt.Sprintf("// Code generated by %s. DO NOT EDIT.\n\n", generatorName)
 	io.WriteString(&buf, generatedByComment)
 
-	fileset := token.NewFileSet()
-
 	// our *ast.File did not come from a real Go source
 	// file. As such, all of its node positions are 0, and
 	// the go/printer package will print the package
@@ -3,12 +3,14 @@ package wsdlgen
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 
 	"aqwari.net/xml/internal/commandline"
 	"aqwari.net/xml/internal/gen"
+	"aqwari.net/xml/xsd"
 	"aqwari.net/xml/xsdgen"
 )
 
@@ -30,22 +32,38 @@ func (cfg *Config) GenCLI(arguments ...string) error {
 		err          error
 		replaceRules commandline.ReplaceRuleList
 		ports        commandline.Strings
+		catalogs     commandline.Strings
 		fs           = flag.NewFlagSet("wsdlgen", flag.ExitOnError)
 		packageName  = fs.String("pkg", "", "name of the generated package")
 		comment      = fs.String("c", "", "First line of package-level comments")
 		output       = fs.String("o", "wsdlgen_output.go", "name of the output file")
+		configFile   = fs.String("config", "", "read options from a JSON file; flags take precedence")
+		follow       = fs.Bool("f", false, "follow import statements; load imported references recursively into scope")
 		verbose      = fs.Bool("v", false, "print verbose output")
 		debug        = fs.Bool("vv", false, "print debug output")
 	)
 	fs.Var(&replaceRules, "r", "replacement rule 'regex -> repl' (can be used multiple times)")
 	fs.Var(&ports, "port", "gen code for this port (can be used multiple times)")
+	fs.Var(&catalogs, "catalog", "XML catalog mapping schema to local files (can be used multiple times)")
 	if err = fs.Parse(arguments); err != nil {
 		return err
 	}
 	if fs.NArg() == 0 {
-		return errors.New("Usage: wsdlgen [-r rule] [-o file] [-port name] [-pkg pkg] file ...")
+		return errors.New("Usage: wsdlgen [-config file] [-f] [-catalog file] [-r rule] [-o file] [-port name] [-pkg pkg] file ...")
 	}
 
+	if *configFile != "" {
+		file, err := ReadConfigFile(*configFile)
+		if err != nil {
+			return err
+		}
+		opts, err := file.Options()
+		if err != nil {
+			return fmt.Errorf("%s: %v", *configFile, err)
+		}
+		cfg.Option(opts...)
+		catalogs = append(file.Catalogs, catalogs...)
+	}
 	if *debug {
 		cfg.Option(LogLevel(5))
 	} else if *verbose {
@@ -61,6 +79,19 @@ func (cfg *Config) GenCLI(arguments ...string) error {
 	if len(ports) > 0 {
 		cfg.Option(OnlyPorts(ports...))
 	}
+	// The -f flag overrides the file only if it is given.
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "f" {
+			cfg.XSDOption(xsdgen.FollowImports(*follow))
+		}
+	})
+	if len(catalogs) > 0 {
+		c, err := xsd.ReadCatalog(catalogs...)
+		if err != nil {
+			return err
+		}
+		cfg.XSDOption(xsdgen.Catalog(c))
+	}
 	for _, r := range replaceRules {
 		cfg.XSDOption(xsdgen.Replace(r.From.String(), r.To))
 	}
@@ -0,0 +1,74 @@
+package wsdlgen
+
+import (
+	"path/filepath"
+
+	"aqwari.net/xml/internal/commandline"
+	"aqwari.net/xml/xsdgen"
+)
+
+// A ConfigFile holds the options of a Config in a JSON document. Along
+// with the options of the wsdlgen package, it has the fields of an
+// xsdgen.ConfigFile, which control the declaration of types; its
+// PackageName and LogLevel apply to both packages. Options that are
+// not set in the file are left unchanged.
+type ConfigFile struct {
+	xsdgen.ConfigFile
+	Ports          []string `json:"ports,omitempty"`
+	PackageComment string   `json:"packageComment,omitempty"`
+	// Set if not nil, as 0 is a valid threshold
+	InputThreshold  *int `json:"inputThreshold,omitempty"`
+	OutputThreshold *int `json:"outputThreshold,omitempty"`
+}
+
+// ReadConfigFile reads a ConfigFile from a JSON document. Unknown
+// fields are an error, so that misspelt options are not ignored.
+// Catalogs are relative to the configuration file.
+func ReadConfigFile(filename string) (*ConfigFile, error) {
+	c := new(ConfigFile)
+	if err := commandline.ReadJSON(filename, c); err != nil {
+		return nil, err
+	}
+	commandline.RelativePaths(filepath.Dir(filename), c.Catalogs)
+	return c, nil
+}
+
+// Options returns the Options set in the ConfigFile, including those
+// of the xsdgen package.
+func (c *ConfigFile) Options() ([]Option, error) {
+	xsdOpts, err := c.ConfigFile.Options()
+	if err != nil {
+		return nil, err
+	}
+	opts := []Option{xsdOptions(xsdOpts...)}
+	if c.PackageName != "" {
+		opts = append(opts, PackageName(c.PackageName))
+	}
+	if c.LogLevel != 0 {
+		opts = append(opts, LogLevel(c.LogLevel))
+	}
+	if len(c.Ports) > 0 {
+		opts = append(opts, OnlyPorts(c.Ports...))
+	}
+	if c.PackageComment != "" {
+		opts = append(opts, PackageComment(c.PackageComment))
+	}
+	if c.InputThreshold != nil {
+		opts = append(opts, InputThreshold(*c.InputThreshold))
+	}
+	if c.OutputThreshold != nil {
+		opts = append(opts, OutputThreshold(*c.OutputThreshold))
+	}
+	return opts, nil
+}
+
+// Sets options of the xsdgen package, as with the XSDOption method.
+func xsdOptions(opts ...xsdgen.Option) Option {
+	return func(cfg *Config) Option {
+		var undo []xsdgen.Option
+		if prev := cfg.XSDOption(opts...); prev != nil {
+			undo = append(undo, prev)
+		}
+		return xsdOptions(undo...)
+	}
+}
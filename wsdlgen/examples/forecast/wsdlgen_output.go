@@ -55,15 +55,84 @@ import (
 // May be one of IsEqual, Between, GreaterThan, GreaterThanEqualTo, LessThan, LessThanEqualTo
 type CompType string
 
+const (
+	CompTypeIsEqual            CompType = "IsEqual"
+	CompTypeBetween            CompType = "Between"
+	CompTypeGreaterThan        CompType = "GreaterThan"
+	CompTypeGreaterThanEqualTo CompType = "GreaterThanEqualTo"
+	CompTypeLessThan           CompType = "LessThan"
+	CompTypeLessThanEqualTo    CompType = "LessThanEqualTo"
+)
+
+// IsValid returns true if v is one of the enumerated values of CompType.
+func (v CompType) IsValid() bool {
+	switch v {
+	case CompTypeIsEqual, CompTypeBetween, CompTypeGreaterThan, CompTypeGreaterThanEqualTo, CompTypeLessThan, CompTypeLessThanEqualTo:
+		return true
+	}
+	return false
+}
+
 // May be one of 1, 2, 3, 4, 12, 34, 1234
 type DisplayLevel int
 
+const (
+	DisplayLevel1    DisplayLevel = 1
+	DisplayLevel2    DisplayLevel = 2
+	DisplayLevel3    DisplayLevel = 3
+	DisplayLevel4    DisplayLevel = 4
+	DisplayLevel12   DisplayLevel = 12
+	DisplayLevel34   DisplayLevel = 34
+	DisplayLevel1234 DisplayLevel = 1234
+)
+
+// IsValid returns true if v is one of the enumerated values of DisplayLevel.
+func (v DisplayLevel) IsValid() bool {
+	switch v {
+	case DisplayLevel1, DisplayLevel2, DisplayLevel3, DisplayLevel4, DisplayLevel12, DisplayLevel34, DisplayLevel1234:
+		return true
+	}
+	return false
+}
+
 // May be one of Forecast_Gml2Point, Forecast_Gml2AllWx, Forecast_GmlsfPoint, Forecast_GmlObs, NdfdMultiPointCoverage, Ndfd_KmlPoint
 type FeatureType string
 
+const (
+	FeatureTypeForecastGml2Point      FeatureType = "Forecast_Gml2Point"
+	FeatureTypeForecastGml2AllWx      FeatureType = "Forecast_Gml2AllWx"
+	FeatureTypeForecastGmlsfPoint     FeatureType = "Forecast_GmlsfPoint"
+	FeatureTypeForecastGmlObs         FeatureType = "Forecast_GmlObs"
+	FeatureTypeNdfdMultiPointCoverage FeatureType = "NdfdMultiPointCoverage"
+	FeatureTypeNdfdKmlPoint           FeatureType = "Ndfd_KmlPoint"
+)
+
+// IsValid returns true if v is one of the enumerated values of FeatureType.
+func (v FeatureType) IsValid() bool {
+	switch v {
+	case FeatureTypeForecastGml2Point, FeatureTypeForecastGml2AllWx, FeatureTypeForecastGmlsfPoint, FeatureTypeForecastGmlObs, FeatureTypeNdfdMultiPointCoverage, FeatureTypeNdfdKmlPoint:
+		return true
+	}
+	return false
+}
+
 // May be one of 24 hourly, 12 hourly
 type Format string
 
+const (
+	Format24Hourly Format = "24 hourly"
+	Format12Hourly Format = "12 hourly"
+)
+
+// IsValid returns true if v is one of the enumerated values of Format.
+func (v Format) IsValid() bool {
+	switch v {
+	case Format24Hourly, Format12Hourly:
+		return true
+	}
+	return false
+}
+
 // Must match the pattern [\-]?\d{1,2}\.\d+,[\-]?\d{1,3}\.\d+
 type LatLonPair string
 
@@ -76,12 +145,59 @@ type ListLatLon string
 // May be one of time-series, glance
 type Product string
 
+const (
+	ProductTimeSeries Product = "time-series"
+	ProductGlance     Product = "glance"
+)
+
+// IsValid returns true if v is one of the enumerated values of Product.
+func (v Product) IsValid() bool {
+	switch v {
+	case ProductTimeSeries, ProductGlance:
+		return true
+	}
+	return false
+}
+
 // May be one of conus, nhemi, alaska, guam, hawaii, puertori, npacocn
 type Sector string
 
+const (
+	SectorConus    Sector = "conus"
+	SectorNhemi    Sector = "nhemi"
+	SectorAlaska   Sector = "alaska"
+	SectorGuam     Sector = "guam"
+	SectorHawaii   Sector = "hawaii"
+	SectorPuertori Sector = "puertori"
+	SectorNpacocn  Sector = "npacocn"
+)
+
+// IsValid returns true if v is one of the enumerated values of Sector.
+func (v Sector) IsValid() bool {
+	switch v {
+	case SectorConus, SectorNhemi, SectorAlaska, SectorGuam, SectorHawaii, SectorPuertori, SectorNpacocn:
+		return true
+	}
+	return false
+}
+
 // May be one of e, m
 type Unit string
 
+const (
+	UnitE Unit = "e"
+	UnitM Unit = "m"
+)
+
+// IsValid returns true if v is one of the enumerated values of Unit.
+func (v Unit) IsValid() bool {
+	switch v {
+	case UnitE, UnitM:
+		return true
+	}
+	return false
+}
+
 type WeatherParameters struct {
 	Maxt         bool `xml:"http://graphical.weather.gov/xml/DWMLgen/schema/DWML.xsd maxt"`
 	Mint         bool `xml:"http://graphical.weather.gov/xml/DWMLgen/schema/DWML.xsd mint"`
@@ -14,7 +14,6 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
-	"io/ioutil"
 	"strings"
 
 	"aqwari.net/xml/internal/gen"
@@ -97,14 +96,9 @@ func (cfg *Config) GenAST(files ...string) (*ast.File, error) {
 	if cfg.pkgHeader == "" {
 		cfg.pkgHeader = fmt.Sprintf("Package %s", cfg.pkgName)
 	}
-	docs := make([][]byte, 0, len(files))
-	for _, filename := range files {
-		if data, err := ioutil.ReadFile(filename); err != nil {
-			return nil, err
-		} else {
-			cfg.debugf("read %s", filename)
-			docs = append(docs, data)
-		}
+	docs, err := cfg.xsdgen.ReadFiles(files...)
+	if err != nil {
+		return nil, err
 	}
 
 	cfg.debugf("parsing WSDL file %s", files[0])
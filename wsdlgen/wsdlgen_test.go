@@ -3,6 +3,7 @@ package wsdlgen
 import (
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
 	"aqwari.net/xml/xsdgen"
@@ -55,3 +56,34 @@ func TestHello(t *testing.T) {
 func TestElementWisePart(t *testing.T) {
 	testGen(t, "testdata/ElementPart.wsdl")
 }
+
+func TestConfigFile(t *testing.T) {
+	file, err := ReadConfigFile("testdata/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := file.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	cfg.Option(DefaultOptions...)
+	cfg.Option(LogOutput(testLogger{t}))
+	cfg.Option(opts...)
+	src, err := cfg.GenSource("../testdata/hello.wsdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range []string{
+		`// Package hello is a client of the hello service.`,
+		`package hello`,
+	} {
+		if !strings.Contains(string(src), pattern) {
+			t.Errorf("generated code does not contain %q", pattern)
+		}
+	}
+	if cfg.maxArgs != 0 {
+		t.Errorf("input threshold is %d, want 0", cfg.maxArgs)
+	}
+	t.Logf("\n%s\n", src)
+}
@@ -49,6 +49,17 @@ type Element struct {
 	Content []byte
 	// Sub-elements contained within this element.
 	Children []Element
+
+	// Position of the start tag in the document passed to Parse.
+	line, col int
+}
+
+// Pos returns the line and column, both starting at 1, of the
+// Element's start tag in the document it was parsed from. Columns
+// are counted in bytes. If the Element was not created by Parse,
+// Pos returns zero for both values.
+func (el *Element) Pos() (line, col int) {
+	return el.line, el.col
 }
 
 // Attr gets the value of the first attribute whose name matches the
@@ -192,16 +203,43 @@ type scanner struct {
 	*xml.Decoder
 	tok xml.Token
 	err error
+
+	// input offset of the start of tok
+	start int64
+
+	// line and column of the input offset counted
+	line, col int
+	counted   int64
 }
 
 func (s *scanner) scan() bool {
 	if s.err != nil {
 		return false
 	}
+	s.start = s.InputOffset()
 	s.tok, s.err = s.Token()
 	return s.err == nil
 }
 
+// position returns the line and column of the start of the current
+// token. Because tokens are scanned in order, we only need to count
+// the new lines between the previous call and the current token.
+func (s *scanner) position(data []byte) (line, col int) {
+	data = data[:cap(data)]
+	if s.line == 0 {
+		s.line, s.col = 1, 1
+	}
+	for ; s.counted < s.start && s.counted < int64(len(data)); s.counted++ {
+		if data[s.counted] == '\n' {
+			s.line++
+			s.col = 1
+		} else {
+			s.col++
+		}
+	}
+	return s.line, s.col
+}
+
 // Parse builds a tree of Elements by reading an XML document.  The
 // byte slice passed to Parse is expected to be a valid XML document
 // with a single root element.
@@ -244,6 +282,7 @@ func Parse(doc []byte) (*Element, error) {
 	if scanner.err != nil {
 		return nil, scanner.err
 	}
+	root.line, root.col = scanner.position(utf8buf.Bytes())
 	if err := root.parse(&scanner, utf8buf.Bytes(), 0); err != nil {
 		return nil, err
 	}
@@ -263,6 +302,7 @@ walk:
 		switch tok := scanner.tok.(type) {
 		case xml.StartElement:
 			child := Element{StartElement: tok.Copy(), Scope: el.Scope}
+			child.line, child.col = scanner.position(data)
 			if err := child.parse(scanner, data, depth+1); err != nil {
 				return err
 			}
@@ -181,6 +181,21 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestPos(t *testing.T) {
+	root := parseDoc(t, googleSOAP)
+	if line, col := root.Pos(); line != 1 || col != 1 {
+		t.Errorf("root element at %d:%d, wanted 1:1", line, col)
+	}
+	body := root.Search("http://schemas.xmlsoap.org/soap/envelope/", "Body")[0]
+	if line, col := body.Pos(); line != 6 || col != 3 {
+		t.Errorf("<Body> at %d:%d, wanted 6:3", line, col)
+	}
+	items := root.Search("", "item")
+	if line, col := items[1].Pos(); line != 28 || col != 11 {
+		t.Errorf("second <item> at %d:%d, wanted 28:11", line, col)
+	}
+}
+
 func TestNSResolution(t *testing.T) {
 	root := parseDoc(t, exampleDoc)
 
@@ -0,0 +1,303 @@
+package xsd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"aqwari.net/xml/xmltree"
+)
+
+const (
+	catalogNS = "urn:oasis:names:tc:entity:xmlns:xml:catalog"
+	xmlNS     = "http://www.w3.org/XML/1998/namespace"
+)
+
+// A Catalog maps the namespaces and locations of schema documents
+// to local copies, as described by an OASIS XML Catalog. It can be
+// used to generate code from schema that refer to remote documents,
+// or that import well-known namespaces without a schemaLocation,
+// without access to the network.
+//
+// A location is looked up in the catalog's system, rewriteSystem,
+// systemSuffix, uri, rewriteURI and uriSuffix entries, in that order.
+// If it is not found, or the reference has no location, the namespace
+// of the reference is looked up in the uri and public entries. The
+// catalogs named by nextCatalog entries are consulted last.
+type Catalog struct {
+	system, uri, public map[string]string
+	rewriteSystem       []catalogPrefix
+	rewriteURI          []catalogPrefix
+	systemSuffix        []catalogPrefix
+	uriSuffix           []catalogPrefix
+	next                []*Catalog
+}
+
+// For rewrite entries, a prefix of the identifier and its replacement.
+// For suffix entries, a suffix and the location it maps to.
+type catalogPrefix struct {
+	match, location string
+}
+
+// ReadCatalog reads one or more OASIS XML Catalog files. The entries of
+// each file are consulted in the order that the files are given.
+func ReadCatalog(files ...string) (*Catalog, error) {
+	result := new(Catalog)
+	for _, filename := range files {
+		c, err := readCatalog(filename, make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+		result.next = append(result.next, c)
+	}
+	return result, nil
+}
+
+func readCatalog(filename string, seen map[string]bool) (*Catalog, error) {
+	filename = resolveLocation("", filename)
+	if seen[filename] {
+		return new(Catalog), nil
+	}
+	seen[filename] = true
+	data, err := DefaultResolver.Resolve(Ref{Location: filename})
+	if err != nil {
+		return nil, err
+	}
+	c, err := parseCatalog(data, filename, seen)
+	if err != nil {
+		return nil, fmt.Errorf("catalog %s: %v", filename, err)
+	}
+	return c, nil
+}
+
+// ParseCatalog parses an OASIS XML Catalog. Relative URIs in the
+// catalog are resolved against base, the location of the catalog.
+func ParseCatalog(data []byte, base string) (*Catalog, error) {
+	return parseCatalog(data, base, make(map[string]bool))
+}
+
+func parseCatalog(data []byte, base string, seen map[string]bool) (*Catalog, error) {
+	root, err := xmltree.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if (root.Name != xml.Name{catalogNS, "catalog"}) {
+		return nil, fmt.Errorf("root element is %s, not catalog", root.Name.Local)
+	}
+	c := &Catalog{
+		system: make(map[string]string),
+		uri:    make(map[string]string),
+		public: make(map[string]string),
+	}
+	if err := c.parseEntries(root, base, seen); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Catalog) parseEntries(root *xmltree.Element, base string, seen map[string]bool) error {
+	if b := root.Attr(xmlNS, "base"); b != "" {
+		base = catalogBase(base, b)
+	}
+	for i := range root.Children {
+		el := &root.Children[i]
+		if el.Name.Space != catalogNS {
+			continue
+		}
+		entryBase := base
+		if b := el.Attr(xmlNS, "base"); b != "" {
+			entryBase = catalogBase(base, b)
+		}
+		location := resolveLocation(entryBase, el.Attr("", "uri"))
+		prefix := catalogBase(entryBase, el.Attr("", "rewritePrefix"))
+		switch el.Name.Local {
+		case "group":
+			if err := c.parseEntries(el, base, seen); err != nil {
+				return err
+			}
+		case "system":
+			c.system[el.Attr("", "systemId")] = location
+		case "uri":
+			c.uri[el.Attr("", "name")] = location
+		case "public":
+			c.public[el.Attr("", "publicId")] = location
+		case "rewriteSystem":
+			c.rewriteSystem = append(c.rewriteSystem,
+				catalogPrefix{el.Attr("", "systemIdStartString"), prefix})
+		case "rewriteURI":
+			c.rewriteURI = append(c.rewriteURI,
+				catalogPrefix{el.Attr("", "uriStartString"), prefix})
+		case "systemSuffix":
+			c.systemSuffix = append(c.systemSuffix,
+				catalogPrefix{el.Attr("", "systemIdSuffix"), location})
+		case "uriSuffix":
+			c.uriSuffix = append(c.uriSuffix,
+				catalogPrefix{el.Attr("", "uriSuffix"), location})
+		case "nextCatalog":
+			next, err := readCatalog(resolveLocation(entryBase, el.Attr("", "catalog")), seen)
+			if err != nil {
+				return err
+			}
+			c.next = append(c.next, next)
+		}
+	}
+	return nil
+}
+
+// Resolves an xml:base or rewritePrefix attribute against base,
+// keeping a trailing slash, so that it can be used as the base of
+// other relative references.
+func catalogBase(base, ref string) string {
+	if ref == "" {
+		return base
+	}
+	s := resolveLocation(base, ref)
+	if strings.HasSuffix(ref, "/") && !strings.HasSuffix(s, "/") {
+		if isURL(s) {
+			s += "/"
+		} else {
+			s += string(filepath.Separator)
+		}
+	}
+	return s
+}
+
+func isURL(location string) bool {
+	u, err := url.Parse(location)
+	return err == nil && len(u.Scheme) > 1
+}
+
+// Lookup returns the location of the local copy of the schema
+// document referenced by ref, if the catalog has one.
+func (c *Catalog) Lookup(ref Ref) (string, bool) {
+	if ref.Location != "" {
+		if loc, ok := c.lookupLocation(ref.Location); ok {
+			return loc, true
+		}
+	}
+	if ref.Namespace != "" {
+		if loc, ok := c.lookupNamespace(ref.Namespace); ok {
+			return loc, true
+		}
+	}
+	return "", false
+}
+
+func (c *Catalog) lookupLocation(id string) (string, bool) {
+	if loc, ok := c.system[id]; ok {
+		return loc, true
+	}
+	if loc, ok := rewrite(c.rewriteSystem, id); ok {
+		return loc, true
+	}
+	if loc, ok := suffix(c.systemSuffix, id); ok {
+		return loc, true
+	}
+	if loc, ok := c.uri[id]; ok {
+		return loc, true
+	}
+	if loc, ok := rewrite(c.rewriteURI, id); ok {
+		return loc, true
+	}
+	if loc, ok := suffix(c.uriSuffix, id); ok {
+		return loc, true
+	}
+	for _, next := range c.next {
+		if loc, ok := next.lookupLocation(id); ok {
+			return loc, true
+		}
+	}
+	return "", false
+}
+
+func (c *Catalog) lookupNamespace(ns string) (string, bool) {
+	if loc, ok := c.uri[ns]; ok {
+		return loc, true
+	}
+	if loc, ok := c.public[ns]; ok {
+		return loc, true
+	}
+	for _, next := range c.next {
+		if loc, ok := next.lookupNamespace(ns); ok {
+			return loc, true
+		}
+	}
+	return "", false
+}
+
+// The longest matching prefix wins.
+func rewrite(entries []catalogPrefix, id string) (string, bool) {
+	var best *catalogPrefix
+	for i, e := range entries {
+		if strings.HasPrefix(id, e.match) && (best == nil || len(e.match) > len(best.match)) {
+			best = &entries[i]
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	rest := id[len(best.match):]
+	if !isURL(best.location) {
+		rest = filepath.FromSlash(rest)
+	}
+	return best.location + rest, true
+}
+
+// The longest matching suffix wins.
+func suffix(entries []catalogPrefix, id string) (string, bool) {
+	var best *catalogPrefix
+	for i, e := range entries {
+		if strings.HasSuffix(id, e.match) && (best == nil || len(e.match) > len(best.match)) {
+			best = &entries[i]
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return best.location, true
+}
+
+// Imports is like the Imports function, but replaces the location
+// of each reference with the location of its local copy, if the
+// catalog has one.
+func (c *Catalog) Imports(data []byte) ([]Ref, error) {
+	refs, err := Imports(data)
+	if err != nil {
+		return nil, err
+	}
+	for i, ref := range refs {
+		if loc, ok := c.Lookup(ref); ok {
+			refs[i].Location = loc
+		}
+	}
+	return refs, nil
+}
+
+// Resolver returns a SchemaResolver that reads the local copy of
+// each document found in the catalog using r, and passes all other
+// references to r unchanged.
+func (c *Catalog) Resolver(r SchemaResolver) SchemaResolver {
+	return catalogResolver{c, r}
+}
+
+type catalogResolver struct {
+	catalog *Catalog
+	r       SchemaResolver
+}
+
+func (cr catalogResolver) Resolve(ref Ref) ([]byte, error) {
+	ref.Location = cr.locate(ref)
+	if ref.Location == "" {
+		return nil, fmt.Errorf("no location for namespace %q", ref.Namespace)
+	}
+	return cr.r.Resolve(ref)
+}
+
+func (cr catalogResolver) locate(ref Ref) string {
+	if loc, ok := cr.catalog.Lookup(ref); ok {
+		return loc
+	}
+	return ref.Location
+}
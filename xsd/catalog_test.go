@@ -0,0 +1,75 @@
+package xsd
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var testCatalog = []byte(`
+<catalog xmlns="urn:oasis:names:tc:entity:xmlns:xml:catalog">
+  <system systemId="http://example.org/a.xsd" uri="local/a.xsd"/>
+  <rewriteSystem systemIdStartString="http://example.org/" rewritePrefix="mirror/"/>
+  <rewriteSystem systemIdStartString="http://example.org/deep/" rewritePrefix="http://mirror.example.net/"/>
+  <systemSuffix systemIdSuffix="/xml.xsd" uri="w3c/xml.xsd"/>
+  <group xml:base="ns/">
+    <uri name="urn:b" uri="b.xsd"/>
+    <public publicId="urn:c" uri="c.xsd"/>
+  </group>
+</catalog>`)
+
+func TestCatalogLookup(t *testing.T) {
+	c, err := ParseCatalog(testCatalog, filepath.Join("catalogs", "catalog.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ref  Ref
+		want string
+	}{
+		{Ref{"", "http://example.org/a.xsd"}, filepath.Join("catalogs", "local", "a.xsd")},
+		{Ref{"", "http://example.org/x/y.xsd"}, filepath.Join("catalogs", "mirror", "x", "y.xsd")},
+		{Ref{"", "http://example.org/deep/y.xsd"}, "http://mirror.example.net/y.xsd"},
+		{Ref{"", "http://www.w3.org/2001/xml.xsd"}, filepath.Join("catalogs", "w3c", "xml.xsd")},
+		{Ref{"urn:b", ""}, filepath.Join("catalogs", "ns", "b.xsd")},
+		{Ref{"urn:c", "http://unknown.example.com/c.xsd"}, filepath.Join("catalogs", "ns", "c.xsd")},
+		{Ref{"urn:d", "d.xsd"}, ""},
+	}
+	for _, tt := range tests {
+		got, ok := c.Lookup(tt.ref)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Lookup(%v) = %q, %v; want %q", tt.ref, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCatalogResolver(t *testing.T) {
+	c, err := ParseCatalog([]byte(`
+		<catalog xmlns="urn:oasis:names:tc:entity:xmlns:xml:catalog">
+		  <uri name="urn:a" uri="local/a.xsd"/>
+		  <system systemId="http://example.org/b.xsd" uri="local/b.xsd"/>
+		</catalog>`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := c.Resolver(MapResolver{
+		"main.xsd": []byte(`
+			<schema xmlns="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:main">
+			  <import namespace="urn:a"/>
+			  <import namespace="urn:b" schemaLocation="http://example.org/b.xsd"/>
+			  <import namespace="urn:none"/>
+			</schema>`),
+		filepath.Join("local", "a.xsd"): []byte(`
+			<schema xmlns="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:a">
+			  <import namespace="urn:b" schemaLocation="b.xsd"/>
+			</schema>`),
+		filepath.Join("local", "b.xsd"): []byte(`
+			<schema xmlns="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:b"/>`),
+	})
+	docs, err := ReadAll(r, "main.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 3 {
+		t.Errorf("read %d documents, want 3", len(docs))
+	}
+}
@@ -40,10 +40,22 @@ type Ref struct {
 	Namespace, Location string
 }
 
+// Elements that bring in the components of another document
+// with the same target namespace.
+var isInclusion = or(
+	isElem(schemaNS, "include"),
+	isElem(schemaNS, "redefine"),
+	isElem(schemaNS, "override"))
+
+// Elements that replace the components of another document.
+var isRedefinition = or(
+	isElem(schemaNS, "redefine"),
+	isElem(schemaNS, "override"))
+
 // Imports reads an XML document containing one or more <schema>
 // elements and returns a list of canonical XML name spaces that
-// the schema imports or includes, along with a URL for the schema,
-// if provided.
+// the schema imports, includes, redefines or overrides, along with
+// a URL for the schema, if provided.
 func Imports(data []byte) ([]Ref, error) {
 	var result []Ref
 
@@ -66,7 +78,7 @@ func Imports(data []byte) ([]Ref, error) {
 
 	for _, tree := range schema {
 		ns := tree.Attr("", "targetNamespace")
-		for _, v := range tree.Search(schemaNS, "include") {
+		for _, v := range tree.SearchFunc(isInclusion) {
 			s := Ref{ns, v.Attr("", "schemaLocation")}
 			result = append(result, s)
 		}
@@ -104,9 +116,13 @@ func Normalize(docs ...[]byte) ([]*xmltree.Element, error) {
 			result = append(result, root.Search(schemaNS, "schema")...)
 		}
 	}
+	if err := applyRedefinitions(result); err != nil {
+		return nil, err
+	}
 	for _, root := range result {
 		attributeDefaultType(root)
 		elementDefaultType(root)
+		setLocalForms(root)
 		copyEltNamesToAnonTypes(root)
 	}
 	typeCounter := 0
@@ -128,10 +144,19 @@ func Normalize(docs ...[]byte) ([]*xmltree.Element, error) {
 }
 
 // Parse reads XML documents containing one or more <schema>
-// elements. The returned slice has one Schema for every <schema>
-// element in the documents. Parse will not fetch schema used in
-// <import> or <include> statements; use the Imports function to
-// find any additional schema documents required for a schema.
+// elements. The returned slice has one Schema for every target
+// namespace of the <schema> elements in the documents, in the order
+// the namespaces first appear, followed by the schema of the built-in
+// types. The components of all <schema> elements with the same target
+// namespace, such as documents that include or redefine one another,
+// are combined into one Schema, so the returned slice may have fewer
+// elements than there are <schema> elements.
+//
+// Parse will not fetch schema used in <import> or <include>
+// statements; use the Imports function to find any additional schema
+// documents required for a schema, or the ReadAll function to
+// retrieve them. ReadAll also gives included documents without a
+// targetNamespace the namespace of the schema that includes them.
 func Parse(docs ...[]byte) ([]Schema, error) {
 	var (
 		result = make([]Schema, 0, len(docs))
@@ -144,9 +169,20 @@ func Parse(docs ...[]byte) ([]Schema, error) {
 		return nil, err
 	}
 
+	// Documents with the same target namespace, such as those
+	// that include or redefine one another, share one Schema.
 	for _, root := range schema {
 		tns := root.Attr("", "targetNamespace")
-		s := Schema{TargetNS: tns, Types: make(map[xml.Name]Type)}
+		s, ok := parsed[tns]
+		if !ok {
+			s = Schema{
+				TargetNS:        tns,
+				Types:           make(map[xml.Name]Type),
+				Elements:        make(map[xml.Name]*Element),
+				Groups:          make(map[xml.Name]*Group),
+				AttributeGroups: make(map[xml.Name]*AttributeGroup),
+			}
+		}
 		if err := s.parse(root); err != nil {
 			return nil, err
 		}
@@ -160,7 +196,8 @@ func Parse(docs ...[]byte) ([]Schema, error) {
 	}
 
 	for _, root := range schema {
-		s := parsed[root.Attr("", "targetNamespace")]
+		tns := root.Attr("", "targetNamespace")
+		s := parsed[tns]
 		if err := s.resolvePartialTypes(types); err != nil {
 			return nil, err
 		}
@@ -168,13 +205,37 @@ func Parse(docs ...[]byte) ([]Schema, error) {
 		if err != nil {
 			return nil, err
 		}
+	}
+	for _, root := range schema {
+		parsed[root.Attr("", "targetNamespace")].removeOriginals(root)
+	}
+	for _, root := range schema {
+		tns := root.Attr("", "targetNamespace")
+		s, ok := parsed[tns]
+		if !ok {
+			continue
+		}
 		s.propagateMixedAttr()
+		s.addElements()
 		result = append(result, s)
+		delete(parsed, tns)
 	}
 	result = append(result, builtinSchema)
 	return result, nil
 }
 
+// The originals of types that are redefined are only used as the
+// base of the types that redefine them, and are not listed in the
+// Types of a Schema.
+func (s Schema) removeOriginals(root *xmltree.Element) {
+	tns := root.Attr("", "targetNamespace")
+	for _, el := range root.Children {
+		if el.Attr("", "_isOriginal") == "true" {
+			delete(s.Types, xml.Name{tns, el.Attr("", "name")})
+		}
+	}
+}
+
 func parseType(name xml.Name) Type {
 	builtin, err := ParseBuiltin(name)
 	if err != nil {
@@ -216,6 +277,7 @@ func copyEltNamesToAnonTypes(root *xmltree.Element) {
 		hasAttr("", "name"),
 		hasAnonymousType)
 
+	var named []xmltree.Element
 	for _, el := range root.SearchFunc(eltWithAnonType) {
 		// Make sure we can use this element's name
 		xmlname := el.ResolveDefault(el.Attr("", "name"), tns)
@@ -232,10 +294,11 @@ func copyEltNamesToAnonTypes(root *xmltree.Element) {
 
 			el.Children = append(el.Children[:i], el.Children[i+1:]...)
 			el.Content = nil
-			root.Children = append(root.Children, t)
+			named = append(named, t)
 			break
 		}
 	}
+	root.Children = append(root.Children, named...)
 }
 
 // Inside a <xs:choice>, set all children to optional
@@ -249,11 +312,11 @@ func setChoicesOptional(root *xmltree.Element) error {
 			if t.Name.Space == schemaNS && t.Name.Local == "sequence" {
 				for j := 0; j < len(t.Children); j++ {
 					t2 := t.Children[j]
-					t2.SetAttr("", "minOccurs", "0")
+					setOptional(&t2)
 					t.Children[j] = t2
 				}
 			} else {
-				t.SetAttr("", "minOccurs", "0")
+				setOptional(&t)
 			}
 
 			el.Children[i] = t
@@ -263,6 +326,19 @@ func setChoicesOptional(root *xmltree.Element) error {
 	return nil
 }
 
+// The original minOccurs value is kept in the _minOccurs attribute,
+// so that the content model of a type can be reconstructed later.
+func setOptional(el *xmltree.Element) {
+	if el.Attr("", "_minOccurs") == "" {
+		min := el.Attr("", "minOccurs")
+		if min == "" {
+			min = "1"
+		}
+		el.SetAttr("", "_minOccurs", min)
+	}
+	el.SetAttr("", "minOccurs", "0")
+}
+
 /*
 Convert
 
@@ -292,6 +368,9 @@ func nameAnonymousTypes(root *xmltree.Element, typeCounter *int) error {
 	var (
 		updateAttr string
 		accum      bool
+		// Appending to root.Children while holding pointers
+		// into it would lose changes made through them.
+		named []xmltree.Element
 	)
 	targetNS := root.Attr("", "targetNamespace")
 	for _, el := range root.SearchFunc(hasAnonymousType) {
@@ -334,12 +413,15 @@ func nameAnonymousTypes(root *xmltree.Element, typeCounter *int) error {
 			el.SetAttr("", updateAttr, qname)
 			el.Children = append(el.Children[:i], el.Children[i+1:]...)
 			el.Content = nil
-			root.Children = append(root.Children, t)
+			named = append(named, t)
 			if !accum {
 				break
 			}
+			// A <union> may contain more than one anonymous type
+			i--
 		}
 	}
+	root.Children = append(root.Children, named...)
 	return nil
 }
 
@@ -387,8 +469,14 @@ func flattenRef(schema []*xmltree.Element) error {
 			panic("bug building dep tree; missing " + el.Attr("", "ref"))
 		}
 		*el = *deref(el, real)
+		if isGroup(el) {
+			setGroupName(el, ref)
+		} else {
+			setRefName(el, ref)
+		}
 	})
 	for ns, doc := range schema {
+		markGroupMembers(doc)
 		unpackGroups(doc)
 		if hasCycle(doc, nil) {
 			return fmt.Errorf("cycle detected after flattening references "+
@@ -398,6 +486,133 @@ func flattenRef(schema []*xmltree.Element) error {
 	return nil
 }
 
+/*
+Apply <redefine> and <override> elements, moving the components
+they contain to the top level of their schema. Each component
+replaces the component of the same name in the document named by
+the schemaLocation of the <redefine> or <override>. Within a
+<redefine>, a type may be derived from, and a group may refer to,
+the component that it redefines; the original component is kept
+with "_" prepended and "_original" appended to its name. An original
+type is anonymous, and is left out of the Types of its Schema.
+
+	<redefine schemaLocation="v1.xsd">
+	  <complexType name="address">
+	    <complexContent>
+	      <extension base="tns:address">
+	        ...
+
+becomes
+
+	<complexType name="address">
+	  <complexContent>
+	    <extension base="tns:_address_original">
+	      ...
+
+and the address type in v1.xsd is renamed to _address_original. The
+components in an <override> do not refer to the originals, which are
+removed. A component in an <override> that has no original is
+ignored.
+
+The location of a document is given by its xml:base attribute, which
+ReadAll adds to the documents that take part in a redefinition. If no
+document has the location that a schemaLocation refers to, all other
+documents with the same target namespace are searched.
+*/
+func applyRedefinitions(schema []*xmltree.Element) error {
+	for _, root := range schema {
+		if !hasChild(isRedefinition)(root) {
+			continue
+		}
+		tns := root.Attr("", "targetNamespace")
+		children := make([]xmltree.Element, 0, len(root.Children))
+		var redefined []xmltree.Element
+		for _, c := range root.Children {
+			if !isRedefinition(&c) {
+				children = append(children, c)
+				continue
+			}
+			location := resolveLocation(root.Attr(xmlNS, "base"), c.Attr("", "schemaLocation"))
+			docs := redefinedDocs(schema, root, tns, location)
+			for _, comp := range c.Children {
+				if comp.Name.Space != schemaNS || comp.Attr("", "name") == "" {
+					continue
+				}
+				name := xml.Name{tns, comp.Attr("", "name")}
+				doc, i, ok := findComponent(docs, comp.Name, name)
+				if c.Name.Local == "override" {
+					if !ok {
+						continue
+					}
+					doc.Children = append(doc.Children[:i], doc.Children[i+1:]...)
+				} else if !ok {
+					return fmt.Errorf("could not find original definition of redefined %s %s",
+						comp.Name.Local, name.Local)
+				} else {
+					original := xml.Name{tns, "_" + name.Local + "_original"}
+					doc.Children[i].SetAttr("", "name", original.Local)
+					doc.Children[i].SetAttr("", "_isAnonymous", "true")
+					doc.Children[i].SetAttr("", "_isOriginal", "true")
+					renameSelfReferences(&comp, name, original)
+				}
+				redefined = append(redefined, comp)
+			}
+		}
+		root.Children = append(children, redefined...)
+	}
+	return nil
+}
+
+// Returns the documents redefined by a <redefine> or <override> in
+// root that refers to location. Documents without a location are
+// searched when none has the location.
+func redefinedDocs(schema []*xmltree.Element, root *xmltree.Element, tns, location string) []*xmltree.Element {
+	var found, unknown []*xmltree.Element
+	for _, doc := range schema {
+		if doc == root || doc.Attr("", "targetNamespace") != tns {
+			continue
+		}
+		switch base := doc.Attr(xmlNS, "base"); {
+		case base == location:
+			found = append(found, doc)
+		case base == "":
+			unknown = append(unknown, doc)
+		}
+	}
+	if len(found) > 0 {
+		return found
+	}
+	return unknown
+}
+
+// Finds the top-level schema component of the given kind and name
+// in one of docs.
+func findComponent(docs []*xmltree.Element, kind, name xml.Name) (*xmltree.Element, int, bool) {
+	for _, doc := range docs {
+		for i, el := range doc.Children {
+			if el.Name == kind && el.Attr("", "name") == name.Local {
+				return doc, i, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// Points the references in a redefined component to the component
+// that it redefines, which has been renamed.
+func renameSelfReferences(comp *xmltree.Element, name, original xml.Name) {
+	attr, refersTo := "base", or(isElem(schemaNS, "restriction"), isElem(schemaNS, "extension"))
+	switch comp.Name.Local {
+	case "group", "attributeGroup":
+		attr, refersTo = "ref", isElem(schemaNS, comp.Name.Local)
+	}
+	for _, el := range comp.SearchFunc(and(refersTo, hasAttr("", attr))) {
+		if el.Resolve(el.Attr("", attr)) == name {
+			el.SetAttr("", attr, el.Prefix(original))
+		}
+	}
+}
+
 // Flatten a reference to an XML element, returning the full XML
 // object.
 func deref(ref, real *xmltree.Element) *xmltree.Element {
@@ -411,7 +626,8 @@ func deref(ref, real *xmltree.Element) *xmltree.Element {
 	// Some attributes can contain a qname, and must be converted to use the
 	// xmlns prefixes in ref's scope.
 	hasQName := map[xml.Name]bool{
-		xml.Name{"", "type"}: true,
+		xml.Name{"", "type"}:              true,
+		xml.Name{"", "substitutionGroup"}: true,
 	}
 	for i, attr := range el.StartElement.Attr {
 		if hasQName[attr.Name] {
@@ -437,19 +653,139 @@ func deref(ref, real *xmltree.Element) *xmltree.Element {
 	return el
 }
 
+var isGroup = or(isElem(schemaNS, "group"), isElem(schemaNS, "attributeGroup"))
+
+// Before dereferenced groups are unpacked, the elements and attributes
+// they contain are marked with the name of the group, so that the
+// parsed Element or Attribute can refer to it. The members of a group
+// that is referenced from another group are attributed to the outer
+// group. Each group is copied before it is marked, because the copies
+// of a group share their descendants.
+func markGroupMembers(el *xmltree.Element) {
+	for i := range el.Children {
+		c := &el.Children[i]
+		if name := groupName(c); isGroup(c) && name.Local != "" {
+			*c = copyTree(*c)
+			setGroupMembers(c, name)
+		} else {
+			markGroupMembers(c)
+		}
+	}
+}
+
+func setGroupMembers(el *xmltree.Element, name xml.Name) {
+	for i := range el.Children {
+		c := &el.Children[i]
+		if c.Name.Space != schemaNS {
+			continue
+		}
+		switch c.Name.Local {
+		case "complexType", "simpleType":
+			continue
+		case "element", "attribute", "any":
+			setGroupName(c, name)
+		}
+		setGroupMembers(c, name)
+	}
+}
+
+// The group name is stored in the namespace and value of the
+// special attribute "_group", as the group may belong to a namespace
+// that has no prefix in the scope of its members.
+func setGroupName(el *xmltree.Element, name xml.Name) {
+	attrs := el.StartElement.Attr[:0:0]
+	for _, attr := range el.StartElement.Attr {
+		if attr.Name.Local != "_group" {
+			attrs = append(attrs, attr)
+		}
+	}
+	el.StartElement.Attr = append(attrs, xml.Attr{
+		Name:  xml.Name{name.Space, "_group"},
+		Value: name.Local,
+	})
+}
+
+func groupName(el *xmltree.Element) xml.Name {
+	for _, attr := range el.StartElement.Attr {
+		if attr.Name.Local == "_group" {
+			return xml.Name{attr.Name.Space, attr.Value}
+		}
+	}
+	return xml.Name{}
+}
+
+// The name of a referenced element or attribute is stored in the
+// namespace and value of the special attribute "_ref", like the name
+// of a group. The declaration it was copied from may belong to
+// another namespace, whose elements and attributes are always
+// qualified.
+func setRefName(el *xmltree.Element, name xml.Name) {
+	el.StartElement.Attr = append(el.StartElement.Attr, xml.Attr{
+		Name:  xml.Name{name.Space, "_ref"},
+		Value: name.Local,
+	})
+}
+
+func refName(el *xmltree.Element) (xml.Name, bool) {
+	for _, attr := range el.StartElement.Attr {
+		if attr.Name.Local == "_ref" {
+			return xml.Name{attr.Name.Space, attr.Value}, true
+		}
+	}
+	return xml.Name{}, false
+}
+
+// instanceName returns the name that an element or attribute
+// declaration has in instance documents. Unqualified local
+// declarations have no namespace, while the declarations copied from
+// a ref= keep the namespace of the declaration they refer to.
+func instanceName(name xml.Name, el *xmltree.Element) xml.Name {
+	if ref, ok := refName(el); ok {
+		return xml.Name{ref.Space, name.Local}
+	}
+	if el.Attr("", "form") == "unqualified" {
+		return xml.Name{"", name.Local}
+	}
+	return name
+}
+
+// Returns a copy of an element that does not share its attributes
+// or any of its descendants with the original.
+func copyTree(el xmltree.Element) xmltree.Element {
+	el.StartElement.Attr = append([]xml.Attr(nil), el.StartElement.Attr...)
+	if el.Children != nil {
+		children := make([]xmltree.Element, len(el.Children))
+		for i, c := range el.Children {
+			children[i] = copyTree(c)
+		}
+		el.Children = children
+	}
+	return el
+}
+
 // After dereferencing groups and attributeGroups, we need to
-// unpack them within their parent elements.
+// unpack them within their parent elements. The occurrence
+// constraints of a group reference are moved to the model group
+// that it contains.
 func unpackGroups(doc *xmltree.Element) {
-	isGroup := or(isElem(schemaNS, "group"), isElem(schemaNS, "attributeGroup"))
 	hasGroups := hasChild(isGroup)
 
 	for _, el := range doc.SearchFunc(hasGroups) {
 		children := make([]xmltree.Element, 0, len(el.Children))
 		for _, c := range el.Children {
-			if isGroup(&c) {
-				children = append(children, c.Children...)
-			} else {
+			if !isGroup(&c) {
 				children = append(children, c)
+				continue
+			}
+			for _, gc := range c.Children {
+				if isModelGroup(&gc) {
+					for _, attr := range []string{"minOccurs", "maxOccurs", "_minOccurs"} {
+						if v := c.Attr("", attr); v != "" {
+							gc.SetAttr("", attr, v)
+						}
+					}
+				}
+				children = append(children, gc)
 			}
 		}
 		el.Children = children
@@ -576,35 +912,71 @@ func propagateMixedAttr(t, b Type, depth int) {
 // 3.2.2 XML Representation of Attribute Declaration Schema Components
 //
 // Specifies that attributes without a type default to anySimpleType.
+// References to other attributes take the type of the attribute they
+// refer to.
 //
 // http://www.w3.org/TR/xmlschema-1/#cAttribute_Declarations
 func attributeDefaultType(root *xmltree.Element) {
 	var (
 		isAttr    = isElem(schemaNS, "attribute")
 		hasNoType = hasAttrValue("", "type", "")
+		hasNoRef  = hasAttrValue("", "ref", "")
 		anyType   = xml.Name{Space: schemaNS, Local: "anySimpleType"}
 	)
-	for _, el := range root.SearchFunc(and(isAttr, hasNoType)) {
+	for _, el := range root.SearchFunc(and(isAttr, hasNoType, hasNoRef)) {
 		el.SetAttr("", "type", el.Prefix(anyType))
 	}
 }
 
 // 3.3.2 XML Representation of Element Declaration Schema Components
 //
-// Elements types default to anyType
+// Elements types default to anyType. References to other elements
+// take the type of the element they refer to.
 //
 // https://www.w3.org/TR/xmlschema-1/#Element_Declaration_details
 func elementDefaultType(root *xmltree.Element) {
 	var (
 		isElement = isElem(schemaNS, "element")
 		hasNoType = hasAttrValue("", "type", "")
+		hasNoRef  = hasAttrValue("", "ref", "")
 		anyType   = xml.Name{Space: schemaNS, Local: "anyType"}
 	)
-	for _, el := range root.SearchFunc(and(isElement, hasNoType)) {
+	for _, el := range root.SearchFunc(and(isElement, hasNoType, hasNoRef)) {
 		el.SetAttr("", "type", el.Prefix(anyType))
 	}
 }
 
+// 3.3.2 XML Representation of Element Declaration Schema Components
+//
+// Local elements and attributes are qualified with the target
+// namespace in instance documents if their form is "qualified", which
+// defaults to the elementFormDefault or attributeFormDefault of their
+// schema. The form of each local declaration is made explicit before
+// the declarations in groups are copied to other schema.
+//
+// https://www.w3.org/TR/xmlschema-1/#declare-element
+func setLocalForms(root *xmltree.Element) {
+	var (
+		isElement = isElem(schemaNS, "element")
+		isAttr    = isElem(schemaNS, "attribute")
+		isLocal   = and(or(isElement, isAttr), hasAttr("", "name"), hasAttrValue("", "form", ""))
+		elemForm  = root.Attr("", "elementFormDefault")
+		attrForm  = root.Attr("", "attributeFormDefault")
+	)
+	for i := range root.Children {
+		for _, el := range root.Children[i].SearchFunc(isLocal) {
+			form := elemForm
+			if isAttr(el) {
+				form = attrForm
+			}
+			if form == "" {
+				form = "unqualified"
+			}
+			el.SetAttr("", "form", form)
+		}
+	}
+}
+
 func (s *Schema) parse(root *xmltree.Element) error {
 	return s.parseTypes(root)
 }
@@ -621,10 +993,84 @@ func (s *Schema) parseTypes(root *xmltree.Element) (err error) {
 		t := s.parseSimpleType(el)
 		s.Types[t.Name] = t
 	}
-	s.Types[xml.Name{tns, "_self"}] = s.parseSelfType(root)
+	for i := range root.Children {
+		switch el := &root.Children[i]; el.Name {
+		case xml.Name{schemaNS, "group"}:
+			g := s.parseGroup(el)
+			s.Groups[g.Name] = g
+		case xml.Name{schemaNS, "attributeGroup"}:
+			g := s.parseAttributeGroup(el)
+			s.AttributeGroups[g.Name] = g
+		}
+	}
+	self := s.parseSelfType(root)
+	if prev, ok := s.Types[xml.Name{tns, "_self"}].(*ComplexType); ok {
+		self = joinSelfTypes(prev, self)
+	}
+	s.Types[xml.Name{tns, "_self"}] = self
 	return err
 }
 
+// Combines the top-level elements of two documents with the
+// same target namespace.
+func joinSelfTypes(a, b *ComplexType) *ComplexType {
+	if a.Content == nil {
+		return b
+	} else if b.Content == nil {
+		return a
+	}
+	have := make(map[xml.Name]bool)
+	for _, el := range a.Elements {
+		have[el.Name] = true
+	}
+	particles := selfParticles(a.Content)
+	for _, p := range selfParticles(b.Content) {
+		if !have[p.Name] {
+			particles = append(particles, p)
+		}
+	}
+	for _, el := range b.Elements {
+		if !have[el.Name] {
+			a.Elements = append(a.Elements, el)
+		}
+	}
+	a.Content = &Particle{
+		Kind:      ChoiceParticle,
+		MinOccurs: a.Content.MinOccurs,
+		MaxOccurs: a.Content.MaxOccurs,
+		Particles: particles,
+	}
+	a.Doc += b.Doc
+	return a
+}
+
+// The content of a document with a single top-level element is
+// that element's particle, rather than a choice.
+func selfParticles(content *Particle) []Particle {
+	if len(content.Particles) == 0 && content.Name.Local != "" {
+		p := *content
+		p.Kind = ElementParticle
+		return []Particle{p}
+	}
+	return content.Particles
+}
+
+// The top-level elements of a schema are the elements of its
+// _self type, whose types have been resolved. Top-level elements
+// are always in the target namespace, whatever the namespace of
+// their type.
+func (s *Schema) addElements() {
+	self, ok := s.Types[xml.Name{s.TargetNS, "_self"}].(*ComplexType)
+	if !ok {
+		return
+	}
+	for _, el := range self.Elements {
+		el := el
+		el.Name.Space = s.TargetNS
+		s.Elements[el.Name] = &el
+	}
+}
+
 func (s *Schema) parseSelfType(root *xmltree.Element) *ComplexType {
 	self := *root
 	self.Content = nil
@@ -639,7 +1085,13 @@ func (s *Schema) parseSelfType(root *xmltree.Element) *ComplexType {
 	self.SetAttr("", "name", "_self")
 	newdoc.Children = []xmltree.Element{self}
 	expandComplexShorthand(&newdoc)
-	return s.parseComplexType(&newdoc.Children[0])
+	t := s.parseComplexType(&newdoc.Children[0])
+
+	// Any one of the top-level elements may be the root of a document.
+	if t.Content != nil {
+		t.Content.Kind = ChoiceParticle
+	}
+	return t
 }
 
 // http://www.w3.org/TR/2004/REC-xmlschema-1-20041028/structures.html#element-complexType
@@ -712,20 +1164,12 @@ func (t *ComplexType) parseComplexContent(ns string, root *xmltree.Element) {
 				break
 			}
 
-			usedElt := make(map[xml.Name]int)
-			for _, v := range el.Search(schemaNS, "element") {
-				elt := parseElement(ns, v)
-				if existing, ok := usedElt[elt.Name]; !ok {
-					usedElt[elt.Name] = len(t.Elements)
-					t.Elements = append(t.Elements, elt)
-				} else {
-					t.Elements[existing] = joinElem(t.Elements[existing], elt)
-				}
-			}
+			t.Elements = append(t.Elements, parseElements(ns, el)...)
 
 			for _, v := range el.Search(schemaNS, "attribute") {
 				t.Attributes = append(t.Attributes, parseAttribute(ns, v))
 			}
+			t.Content = parseContentModel(ns, el)
 		case "annotation":
 			doc = doc.append(parseAnnotation(el))
 		default:
@@ -735,6 +1179,130 @@ func (t *ComplexType) parseComplexContent(ns string, root *xmltree.Element) {
 	t.Doc += string(doc)
 }
 
+// http://www.w3.org/TR/2004/REC-xmlschema-1-20041028/structures.html#element-group
+func (s *Schema) parseGroup(root *xmltree.Element) *Group {
+	var doc annotation
+	g := Group{
+		Name:     root.ResolveDefault(root.Attr("", "name"), s.TargetNS),
+		Elements: parseElements(s.TargetNS, root),
+		Content:  parseContentModel(s.TargetNS, root),
+	}
+	walk(root, func(el *xmltree.Element) {
+		if el.Name.Local == "annotation" {
+			doc = doc.append(parseAnnotation(el))
+		}
+	})
+	g.Doc = string(doc)
+	return &g
+}
+
+// http://www.w3.org/TR/2004/REC-xmlschema-1-20041028/structures.html#element-attributeGroup
+func (s *Schema) parseAttributeGroup(root *xmltree.Element) *AttributeGroup {
+	var doc annotation
+	g := AttributeGroup{
+		Name: root.ResolveDefault(root.Attr("", "name"), s.TargetNS),
+	}
+	walk(root, func(el *xmltree.Element) {
+		if el.Name.Local == "annotation" {
+			doc = doc.append(parseAnnotation(el))
+		}
+	})
+	for _, v := range root.Search(schemaNS, "attribute") {
+		g.Attributes = append(g.Attributes, parseAttribute(s.TargetNS, v))
+	}
+	g.Doc = string(doc)
+	return &g
+}
+
+// Parses the elements declared within root. An element that is
+// declared more than once is only returned once.
+func parseElements(ns string, root *xmltree.Element) []Element {
+	var elements []Element
+	usedElt := make(map[xml.Name]int)
+	for _, v := range root.Search(schemaNS, "element") {
+		elt := parseElement(ns, v)
+		if existing, ok := usedElt[elt.Name]; !ok {
+			usedElt[elt.Name] = len(elements)
+			elements = append(elements, elt)
+		} else {
+			elements[existing] = joinElem(elements[existing], elt)
+		}
+	}
+	return elements
+}
+
+var isModelGroup = or(
+	isElem(schemaNS, "sequence"),
+	isElem(schemaNS, "choice"),
+	isElem(schemaNS, "all"))
+
+// Builds the content model from the children of an <extension> or
+// <restriction> element. Multiple particles are treated as members of
+// a sequence.
+func parseContentModel(ns string, root *xmltree.Element) *Particle {
+	var particles []Particle
+	walk(root, func(el *xmltree.Element) {
+		if p, ok := parseParticle(ns, el); ok {
+			particles = append(particles, p)
+		}
+	})
+	switch len(particles) {
+	case 0:
+		return nil
+	case 1:
+		return &particles[0]
+	}
+	return &Particle{
+		Kind:      SequenceParticle,
+		MinOccurs: 1,
+		MaxOccurs: 1,
+		Particles: particles,
+	}
+}
+
+func parseParticle(ns string, el *xmltree.Element) (Particle, bool) {
+	var p Particle
+	switch el.Name.Local {
+	case "element":
+		p.Kind = ElementParticle
+		p.Name = parseElement(ns, el).Name
+	case "any":
+		p.Kind = WildcardParticle
+	case "sequence":
+		p.Kind = SequenceParticle
+	case "choice":
+		p.Kind = ChoiceParticle
+	case "all":
+		p.Kind = AllParticle
+	default:
+		return p, false
+	}
+	p.MinOccurs, p.MaxOccurs = parseOccurs(el)
+	if isModelGroup(el) {
+		walk(el, func(el *xmltree.Element) {
+			if child, ok := parseParticle(ns, el); ok {
+				p.Particles = append(p.Particles, child)
+			}
+		})
+	}
+	return p, true
+}
+
+// Returns the minOccurs and maxOccurs of a particle, before
+// setChoicesOptional made the members of a choice optional.
+func parseOccurs(el *xmltree.Element) (min, max int) {
+	min, max = 1, 1
+	if v := el.Attr("", "_minOccurs"); v != "" {
+		min = parseInt(v)
+	} else if v := el.Attr("", "minOccurs"); v != "" {
+		min = parseInt(v)
+	}
+	if v := el.Attr("", "maxOccurs"); v != "" {
+		max = parseInt(v)
+	}
+	return min, max
+}
+
 func joinElem(a, b Element) Element {
 	if a.Doc != "" {
 		a.Doc += "\n"
@@ -748,6 +1316,12 @@ func joinElem(a, b Element) Element {
 	if a.Default != b.Default {
 		a.Default = ""
 	}
+	if a.Fixed != b.Fixed {
+		a.Fixed = ""
+	}
+	if a.Group != b.Group {
+		a.Group = xml.Name{}
+	}
 
 	return a
 }
@@ -811,6 +1385,7 @@ func parseAnyElement(ns string, el *xmltree.Element) Element {
 		Plural:   parsePlural(el),
 		Type:     base,
 		Wildcard: true,
+		Group:    groupName(el),
 	}
 }
 
@@ -820,11 +1395,17 @@ func parseElement(ns string, el *xmltree.Element) Element {
 		Name:     el.ResolveDefault(el.Attr("", "name"), ns),
 		Type:     parseType(el.Resolve(el.Attr("", "type"))),
 		Default:  el.Attr("", "default"),
+		Fixed:    el.Attr("", "fixed"),
 		Abstract: parseBool(el.Attr("", "abstract")),
 		Nillable: parseBool(el.Attr("", "nillable")),
 		Plural:   parsePlural(el),
+		Group:    groupName(el),
 		Scope:    el.Scope,
 	}
+	e.instance = instanceName(e.Name, el)
+	if head := el.Attr("", "substitutionGroup"); head != "" {
+		e.SubstitutionGroup = el.Resolve(head)
+	}
 	if el.Attr("", "type") == "" {
 		e.Type = AnyType
 	}
@@ -857,10 +1438,13 @@ func parseAttribute(ns string, el *xmltree.Element) Attribute {
 		a.Name.Local = name
 	}
 	a.Name.Space = ns
+	a.instance = instanceName(a.Name, el)
 	a.Type = parseType(el.Resolve(el.Attr("", "type")))
 	a.Default = el.Attr("", "default")
+	a.Fixed = el.Attr("", "fixed")
 	a.Scope = el.Scope
 	a.Optional = el.Attr("", "use") != "required"
+	a.Group = groupName(el)
 
 	walk(el, func(el *xmltree.Element) {
 		if el.Name.Local == "annotation" {
@@ -923,14 +1507,21 @@ func parseSimpleRestriction(root *xmltree.Element, base Type) Restriction {
 			r.Enum = append(r.Enum, el.Attr("", "value"))
 		case "minExclusive", "minInclusive":
 			r.MinDate, r.Min = parseMinMaxRestriction(el, base)
+			r.HasMin = r.MinDate.IsZero()
+			r.MinExclusive = el.Name.Local == "minExclusive"
 		case "maxExclusive", "maxInclusive":
 			r.MaxDate, r.Max = parseMinMaxRestriction(el, base)
+			r.HasMax = r.MaxDate.IsZero()
+			r.MaxExclusive = el.Name.Local == "maxExclusive"
 		case "length":
 			r.Length = parseInt(el.Attr("", "value"))
+			r.HasLength = true
 		case "maxLength":
 			r.MaxLength = parseInt(el.Attr("", "value"))
+			r.HasMaxLength = true
 		case "minLength":
 			r.MinLength = parseInt(el.Attr("", "value"))
+			r.HasMinLength = true
 		case "pattern":
 			// We don't fully implement XML Schema's pattern language, and
 			// we don't want to stop a parse because of this. Instead, if we
@@ -1094,6 +1685,34 @@ func (s *Schema) resolvePartialTypes(types map[xml.Name]Type) error {
 			panic(fmt.Sprintf("Unexpected type %s (%T) in Schema.Types map", name.Local, t))
 		}
 	}
+	for name, g := range s.Groups {
+		for i, e := range g.Elements {
+			ref, ok := e.Type.(linkedType)
+			if !ok {
+				continue
+			}
+			real, ok := s.lookupType(ref, types)
+			if !ok {
+				return fmt.Errorf("group %s: could not find type %q in namespace %s for element %s",
+					name.Local, ref.Local, ref.Space, e.Name.Local)
+			}
+			g.Elements[i].Type = real
+		}
+	}
+	for name, g := range s.AttributeGroups {
+		for i, a := range g.Attributes {
+			ref, ok := a.Type.(linkedType)
+			if !ok {
+				continue
+			}
+			real, ok := s.lookupType(ref, types)
+			if !ok {
+				return fmt.Errorf("attributeGroup %s: could not find type %s in namespace %s for attribute %s",
+					name.Local, ref.Local, ref.Space, a.Name.Local)
+			}
+			g.Attributes[i].Type = real
+		}
+	}
 	return nil
 }
 
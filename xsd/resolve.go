@@ -0,0 +1,414 @@
+package xsd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"aqwari.net/xml/xmltree"
+)
+
+// A SchemaResolver retrieves the schema documents referenced by
+// <import> and <include> statements. The Location of a Ref passed
+// to Resolve is either an absolute URL or a file path; relative
+// schemaLocation attributes are resolved against the location of
+// the document containing them before Resolve is called.
+type SchemaResolver interface {
+	Resolve(ref Ref) ([]byte, error)
+}
+
+// A FileResolver reads schema documents from the local file system.
+// Locations may be file paths or file:// URLs.
+type FileResolver struct{}
+
+// Resolve reads the file named by ref.Location.
+func (FileResolver) Resolve(ref Ref) ([]byte, error) {
+	name := ref.Location
+	if u, err := url.Parse(name); err == nil && u.Scheme == "file" {
+		name = filepath.FromSlash(u.Path)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// A DirResolver reads schema documents from a directory, regardless
+// of where they are referenced from. Relative locations are read from
+// the directory itself; a URL is read from the path formed by its host
+// and path within the directory, so that a local copy of remote schema
+// can be kept in the same layout as their origin.
+type DirResolver string
+
+// Resolve reads the file for ref.Location within the directory.
+func (dir DirResolver) Resolve(ref Ref) ([]byte, error) {
+	name := filepath.ToSlash(ref.Location)
+	if u, err := url.Parse(ref.Location); err == nil && len(u.Scheme) > 1 {
+		name = u.Host + "/" + u.Path
+	}
+	name = path.Clean("/" + name)
+	return ioutil.ReadFile(filepath.Join(string(dir), filepath.FromSlash(name)))
+}
+
+// A MapResolver holds schema documents in memory, keyed by their
+// location.
+type MapResolver map[string][]byte
+
+// Resolve returns the document stored under ref.Location.
+func (m MapResolver) Resolve(ref Ref) ([]byte, error) {
+	if data, ok := m[ref.Location]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("no schema for location %q", ref.Location)
+}
+
+// An HTTPResolver fetches schema documents from http and https URLs.
+// If Client is nil, a client that gives up on a request after 30
+// seconds is used.
+type HTTPResolver struct {
+	Client *http.Client
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Resolve fetches the document at the URL ref.Location.
+func (r HTTPResolver) Resolve(ref Ref) ([]byte, error) {
+	client := r.Client
+	if client == nil {
+		client = httpClient
+	}
+	rsp, err := client.Get(ref.Location)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", ref.Location, rsp.Status)
+	}
+	return ioutil.ReadAll(rsp.Body)
+}
+
+// DefaultResolver fetches http and https URLs with an HTTPResolver,
+// and reads all other locations with a FileResolver. Each URL is
+// fetched at most once, as with CachingResolver, while files are read
+// each time they are resolved.
+var DefaultResolver SchemaResolver = defaultResolver{CachingResolver(HTTPResolver{})}
+
+type defaultResolver struct {
+	http SchemaResolver
+}
+
+func (r defaultResolver) Resolve(ref Ref) ([]byte, error) {
+	if isHTTP(ref.Location) {
+		return r.http.Resolve(ref)
+	}
+	return FileResolver{}.Resolve(ref)
+}
+
+func isHTTP(location string) bool {
+	u, err := url.Parse(location)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// CachingResolver returns a SchemaResolver that remembers the
+// documents returned by r, so that each location is retrieved at
+// most once. It is safe for concurrent use.
+func CachingResolver(r SchemaResolver) SchemaResolver {
+	return &cachingResolver{r: r, cache: make(map[string][]byte)}
+}
+
+type cachingResolver struct {
+	r     SchemaResolver
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+func (c *cachingResolver) Resolve(ref Ref) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if data, ok := c.cache[ref.Location]; ok {
+		return data, nil
+	}
+	data, err := c.r.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	c.cache[ref.Location] = data
+	return data, nil
+}
+
+// resolveLocation resolves a schemaLocation found in the document at
+// base. URLs are resolved as URL references, and file paths relative
+// to the directory containing base.
+func resolveLocation(base, location string) string {
+	if location == "" {
+		return ""
+	}
+	if isURL(location) {
+		return location
+	}
+	if b, err := url.Parse(base); err == nil && len(b.Scheme) > 1 {
+		if u, err := url.Parse(location); err == nil {
+			return b.ResolveReference(u).String()
+		}
+		return location
+	}
+	if base == "" || filepath.IsAbs(location) || strings.HasPrefix(location, "/") {
+		return filepath.Clean(location)
+	}
+	return filepath.Join(filepath.Dir(base), location)
+}
+
+// ReadAll reads the schema documents at each location using r,
+// along with every document that they import or include, directly
+// or indirectly. Each document is read only once, so schema that
+// import each other can be read. The documents at locations come
+// first in the returned slice, in the same order, followed by the
+// documents they reference. Imports without a schemaLocation are
+// skipped, unless r is the Resolver of a Catalog that has a location
+// for their namespace.
+//
+// A document without a targetNamespace that is included by a schema
+// with one, known as a chameleon include, takes on the target
+// namespace of the including schema. ReadAll returns a copy of the
+// document for each namespace it is included into, declared with
+// that targetNamespace, so that Parse merges its components into
+// those of the including schema.
+//
+// Documents that redefine or override another document, and the
+// documents that they redefine, are given an xml:base attribute with
+// their location, so that Parse applies each <redefine> or <override>
+// to the document named by its schemaLocation.
+func ReadAll(r SchemaResolver, locations ...string) ([][]byte, error) {
+	rd := reader{
+		r:          r,
+		seen:       make(map[string]bool),
+		chameleons: make(map[string][]byte),
+	}
+	var docs []readDoc
+	for _, loc := range locations {
+		ref := Ref{Location: resolveLocation("", loc)}
+		loc = rd.locate(ref)
+		if rd.seen[loc] {
+			continue
+		}
+		rd.seen[loc] = true
+		data, err := r.Resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := adoptNamespace(data, ""); ok {
+			rd.chameleons[loc] = data
+			rd.seen[loc+" "] = true
+		}
+		docs = append(docs, readDoc{loc, data})
+	}
+	for _, doc := range docs {
+		if err := rd.imports(doc.location, doc.data); err != nil {
+			return nil, err
+		}
+	}
+	docs = rd.markRedefinitions(append(docs, rd.result...))
+	result := make([][]byte, 0, len(docs))
+	for _, doc := range docs {
+		result = append(result, doc.data)
+	}
+	return result, nil
+}
+
+type readDoc struct {
+	location string
+	data     []byte
+}
+
+// A locator is a SchemaResolver that reads documents from a location
+// other than the one in the Ref, such as the resolver returned by
+// Catalog.Resolver. Locate returns the location that is read, which
+// may be empty if the resolver does not know of one.
+type locator interface {
+	locate(ref Ref) string
+}
+
+type reader struct {
+	r      SchemaResolver
+	seen   map[string]bool
+	result []readDoc
+
+	// The original content of documents without a
+	// targetNamespace, by location.
+	chameleons map[string][]byte
+}
+
+func (rd *reader) locate(ref Ref) string {
+	if l, ok := rd.r.(locator); ok {
+		return l.locate(ref)
+	}
+	return ref.Location
+}
+
+func (rd *reader) imports(base string, data []byte) error {
+	refs, err := Imports(data)
+	if err != nil {
+		return fmt.Errorf("error discovering imports of %s: %v", base, err)
+	}
+	for _, ref := range refs {
+		ref.Location = resolveLocation(base, ref.Location)
+		location := rd.locate(ref)
+		if location == "" {
+			continue
+		}
+		b, chameleon := rd.chameleons[location]
+		if !chameleon {
+			if rd.seen[location] {
+				continue
+			}
+			rd.seen[location] = true
+			b, err = rd.r.Resolve(ref)
+			if err != nil {
+				return fmt.Errorf("%s: error reading %s: %v", base, location, err)
+			}
+			if _, chameleon = adoptNamespace(b, ""); chameleon {
+				rd.chameleons[location] = b
+			}
+		}
+		if chameleon {
+			// A copy is made for each namespace that the
+			// document is included into.
+			key := location + " " + ref.Namespace
+			if rd.seen[key] {
+				continue
+			}
+			rd.seen[key] = true
+			b, _ = adoptNamespace(b, ref.Namespace)
+		}
+		rd.result = append(rd.result, readDoc{location, b})
+		if err := rd.imports(location, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markRedefinitions adds an xml:base attribute to the documents
+// that redefine or override another document, and to the documents
+// that they redefine.
+func (rd *reader) markRedefinitions(docs []readDoc) []readDoc {
+	targets := make(map[string]string)
+	for i, doc := range docs {
+		root, err := xmltree.Parse(doc.data)
+		if err != nil {
+			continue
+		}
+		redefinitions := root.SearchFunc(isRedefinition)
+		for _, el := range redefinitions {
+			ref := Ref{Location: resolveLocation(doc.location, el.Attr("", "schemaLocation"))}
+			targets[rd.locate(ref)] = ref.Location
+		}
+		if len(redefinitions) > 0 {
+			docs[i].data = setBase(doc.data, doc.location)
+		}
+	}
+	for i, doc := range docs {
+		if location, ok := targets[doc.location]; ok {
+			docs[i].data = setBase(doc.data, location)
+		}
+	}
+	return docs
+}
+
+// setBase returns a copy of a document with an xml:base attribute
+// on its root element, unless it already has one.
+func setBase(data []byte, location string) []byte {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		offset := d.InputOffset()
+		tok, err := d.RawToken()
+		if err != nil {
+			return data
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range start.Attr {
+			if attr.Name.Space == "xml" && attr.Name.Local == "base" {
+				return data
+			}
+		}
+		name := start.Name.Local
+		if start.Name.Space != "" {
+			name = start.Name.Space + ":" + name
+		}
+		var buf bytes.Buffer
+		at := int(offset) + len("<"+name)
+		buf.Write(data[:at])
+		buf.WriteString(` xml:base="`)
+		xml.EscapeText(&buf, []byte(location))
+		buf.WriteString(`"`)
+		buf.Write(data[at:])
+		return buf.Bytes()
+	}
+}
+
+// adoptNamespace returns a copy of a schema document that has no
+// targetNamespace, declared with the target namespace ns. If the
+// document does not declare a default namespace, ns becomes the
+// default namespace, so that unqualified references to the document's
+// own components refer to their new names. The second return value
+// is false if data is not a schema document without a targetNamespace,
+// in which case data is returned unchanged, as it is if ns is empty.
+func adoptNamespace(data []byte, ns string) ([]byte, bool) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		offset := d.InputOffset()
+		tok, err := d.RawToken()
+		if err != nil {
+			return data, false
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		var prefix, targetNamespace string
+		var hasDefault bool
+		decl := map[string]string{}
+		for _, attr := range start.Attr {
+			switch {
+			case attr.Name.Space == "xmlns":
+				decl[attr.Name.Local] = attr.Value
+			case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+				decl[""] = attr.Value
+				hasDefault = true
+			case attr.Name.Space == "" && attr.Name.Local == "targetNamespace":
+				targetNamespace = attr.Value
+			}
+		}
+		if start.Name.Space != "" {
+			prefix = start.Name.Space + ":"
+		}
+		if start.Name.Local != "schema" || decl[start.Name.Space] != schemaNS || targetNamespace != "" {
+			return data, false
+		}
+		if ns == "" {
+			return data, true
+		}
+		var buf bytes.Buffer
+		at := int(offset) + len("<"+prefix+start.Name.Local)
+		buf.Write(data[:at])
+		buf.WriteString(` targetNamespace="`)
+		xml.EscapeText(&buf, []byte(ns))
+		buf.WriteString(`"`)
+		if !hasDefault {
+			buf.WriteString(` xmlns="`)
+			xml.EscapeText(&buf, []byte(ns))
+			buf.WriteString(`"`)
+		}
+		buf.Write(data[at:])
+		return buf.Bytes(), true
+	}
+}
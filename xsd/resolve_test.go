@@ -0,0 +1,193 @@
+package xsd
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveLocation(t *testing.T) {
+	tests := []struct {
+		base, location, want string
+	}{
+		{"", "a.xsd", "a.xsd"},
+		{"", "./dir/../a.xsd", "a.xsd"},
+		{"schema/main.xsd", "types.xsd", filepath.Join("schema", "types.xsd")},
+		{"schema/main.xsd", "../common/types.xsd", filepath.Join("common", "types.xsd")},
+		{"schema/main.xsd", "http://example.org/a.xsd", "http://example.org/a.xsd"},
+		{"http://example.org/s/main.xsd", "types.xsd", "http://example.org/s/types.xsd"},
+		{"http://example.org/s/main.xsd", "../b.xsd", "http://example.org/b.xsd"},
+		{"main.xsd", "", ""},
+	}
+	for _, tt := range tests {
+		if got := resolveLocation(tt.base, tt.location); got != tt.want {
+			t.Errorf("resolveLocation(%q, %q) = %q, want %q", tt.base, tt.location, got, tt.want)
+		}
+	}
+}
+
+var cyclicSchema = MapResolver{
+	"main.xsd": []byte(`
+		<schema xmlns="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:main">
+		  <import namespace="urn:a" schemaLocation="sub/a.xsd"/>
+		  <import namespace="urn:none"/>
+		</schema>`),
+	filepath.Join("sub", "a.xsd"): []byte(`
+		<schema xmlns="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:a">
+		  <import namespace="urn:b" schemaLocation="b.xsd"/>
+		</schema>`),
+	filepath.Join("sub", "b.xsd"): []byte(`
+		<schema xmlns="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:b">
+		  <import namespace="urn:a" schemaLocation="a.xsd"/>
+		  <import namespace="urn:main" schemaLocation="../main.xsd"/>
+		</schema>`),
+}
+
+func TestReadAll(t *testing.T) {
+	docs, err := ReadAll(cyclicSchema, "main.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("read %d documents, want 3", len(docs))
+	}
+	if string(docs[0]) != string(cyclicSchema["main.xsd"]) {
+		t.Errorf("first document is not main.xsd: %s", docs[0])
+	}
+	if _, err := Parse(docs...); err != nil {
+		t.Error(err)
+	}
+	if _, err := ReadAll(cyclicSchema, "sub/missing.xsd"); err == nil {
+		t.Error("expected error reading a missing document")
+	}
+}
+
+func TestCachingResolver(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/main.xsd":
+			w.Write([]byte(`<schema xmlns="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:main">
+			  <import namespace="urn:a" schemaLocation="a.xsd"/>
+			</schema>`))
+		case "/a.xsd":
+			w.Write([]byte(`<schema xmlns="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:a"/>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	r := CachingResolver(HTTPResolver{})
+	for i := 0; i < 2; i++ {
+		docs, err := ReadAll(r, srv.URL+"/main.xsd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(docs) != 2 {
+			t.Fatalf("read %d documents, want 2", len(docs))
+		}
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+	if _, err := r.Resolve(Ref{Location: srv.URL + "/missing.xsd"}); err == nil {
+		t.Error("expected error for missing document")
+	}
+
+	// The DefaultResolver fetches each URL only once, too.
+	requests = 0
+	for i := 0; i < 2; i++ {
+		if _, err := ReadAll(DefaultResolver, srv.URL+"/main.xsd"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("DefaultResolver made %d requests, want 2", requests)
+	}
+}
+
+func TestDirResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "www.w3.org", "2001", "xml.xsd")
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte("<schema/>"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	for _, loc := range []string{"http://www.w3.org/2001/xml.xsd", "www.w3.org/2001/xml.xsd"} {
+		data, err := DirResolver(dir).Resolve(Ref{Location: loc})
+		if err != nil {
+			t.Errorf("%s: %v", loc, err)
+		} else if string(data) != "<schema/>" {
+			t.Errorf("%s: got %q", loc, data)
+		}
+	}
+}
+
+func TestChameleonInclude(t *testing.T) {
+	r := MapResolver{
+		"a.xsd": []byte(`
+			<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:a="urn:a" targetNamespace="urn:a">
+			  <xs:include schemaLocation="common.xsd"/>
+			  <xs:import namespace="urn:b" schemaLocation="b.xsd"/>
+			  <xs:complexType name="person">
+			    <xs:sequence>
+			      <xs:element name="name" type="a:name"/>
+			    </xs:sequence>
+			  </xs:complexType>
+			</xs:schema>`),
+		"b.xsd": []byte(`
+			<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:b">
+			  <xs:include schemaLocation="common.xsd"/>
+			</xs:schema>`),
+		"common.xsd": []byte(`
+			<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+			  <xs:simpleType name="name">
+			    <xs:restriction base="nameBase"/>
+			  </xs:simpleType>
+			  <xs:simpleType name="nameBase">
+			    <xs:restriction base="xs:string"/>
+			  </xs:simpleType>
+			</xs:schema>`),
+	}
+	docs, err := ReadAll(r, "a.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 4 {
+		t.Errorf("read %d documents, want 4", len(docs))
+	}
+	schema, err := Parse(docs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := make(map[xml.Name]Type)
+	for _, s := range schema {
+		for name, t := range s.Types {
+			types[name] = t
+		}
+	}
+	for _, ns := range []string{"urn:a", "urn:b"} {
+		name, ok := types[xml.Name{ns, "name"}].(*SimpleType)
+		if !ok {
+			t.Errorf("type name not found in namespace %s", ns)
+		} else if XMLName(name.Base) != (xml.Name{ns, "nameBase"}) {
+			t.Errorf("type {%s}name has base %v", ns, XMLName(name.Base))
+		}
+	}
+	if _, ok := types[xml.Name{"", "name"}]; ok {
+		t.Error("included type name kept its empty namespace")
+	}
+}
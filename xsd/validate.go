@@ -0,0 +1,814 @@
+package xsd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"aqwari.net/xml/xmltree"
+)
+
+// A ValidationError describes a part of an XML document that does
+// not conform to the schema it was validated against.
+type ValidationError struct {
+	// Slash-separated local names of the element's ancestors,
+	// beginning with the root element.
+	Path string
+	// Position of the element's start tag in the source document.
+	// Both are zero if the element was not created by xmltree.Parse.
+	Line, Column int
+	// Description of the problem
+	Message string
+}
+
+func (err ValidationError) Error() string {
+	if err.Line == 0 {
+		return err.Path + ": " + err.Message
+	}
+	return fmt.Sprintf("%s (line %d, column %d): %s", err.Path, err.Line, err.Column, err.Message)
+}
+
+type validator struct {
+	roots    []Element
+	types    map[xml.Name]Type
+	patterns map[*regexp.Regexp]*regexp.Regexp
+	errs     []ValidationError
+}
+
+// Validate checks an XML document against the declarations in a set of
+// schema, as returned by Parse. The root element of doc must match one
+// of the top-level elements declared in schemas. Validate checks that
+// child elements follow the content model of their parent's type,
+// the presence of required attributes, and that the values of
+// attributes and simple elements conform to their types, including
+// the facets described by each SimpleType's Restriction. Validate
+// returns one ValidationError for each problem found; a conforming
+// document returns nil.
+//
+// Validate does not check identity constraints (key, keyref, unique),
+// and does not fetch schema referenced by xsi:schemaLocation.
+func Validate(schemas []Schema, doc *xmltree.Element) []ValidationError {
+	v := validator{
+		types:    make(map[xml.Name]Type),
+		patterns: make(map[*regexp.Regexp]*regexp.Regexp),
+	}
+	for _, s := range schemas {
+		for name, t := range s.Types {
+			if name.Local != "_self" {
+				v.types[name] = t
+			}
+		}
+		for _, el := range s.Elements {
+			v.roots = append(v.roots, *el)
+		}
+	}
+	path := "/" + doc.Name.Local
+	decl, ok := v.root(doc.Name)
+	if !ok {
+		v.errorf(path, doc, "no declaration for root element %s", doc.Name.Local)
+		return v.errs
+	}
+	v.element(path, &decl, doc)
+	return v.errs
+}
+
+func (v *validator) errorf(path string, el *xmltree.Element, format string, args ...interface{}) {
+	line, col := el.Pos()
+	v.errs = append(v.errs, ValidationError{
+		Path:    path,
+		Line:    line,
+		Column:  col,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) root(name xml.Name) (Element, bool) {
+	for _, el := range v.roots {
+		if el.instance == name {
+			return el, true
+		}
+	}
+	return Element{}, false
+}
+
+func (v *validator) element(path string, decl *Element, el *xmltree.Element) {
+	if decl.Nillable && parseBoolValue(el.Attr(schemaInstanceNS, "nil")) {
+		if len(el.Children) > 0 {
+			v.errorf(path, el, "nil element may not have content")
+		}
+		return
+	}
+	t := decl.Type
+	if xsiType := el.Attr(schemaInstanceNS, "type"); xsiType != "" {
+		name := el.Resolve(xsiType)
+		if b, err := ParseBuiltin(name); err == nil {
+			t = b
+		} else if real, ok := v.types[name]; ok {
+			t = real
+		} else {
+			v.errorf(path, el, "unknown xsi:type %s", xsiType)
+			return
+		}
+	}
+	switch t := t.(type) {
+	case *ComplexType:
+		v.complexType(path, t, el)
+	case Builtin:
+		if t != AnyType {
+			v.simpleElement(path, t, el)
+		}
+	default:
+		v.simpleElement(path, t, el)
+	}
+}
+
+func (v *validator) simpleElement(path string, t Type, el *xmltree.Element) {
+	if len(el.Children) > 0 {
+		v.errorf(path, el, "element of simple type %s may not contain elements",
+			XMLName(t).Local)
+		return
+	}
+	v.simpleContent(path, t, el)
+}
+
+func (v *validator) simpleContent(path string, t Type, el *xmltree.Element) {
+	var text string
+	if err := xmltree.Unmarshal(el, &text); err != nil {
+		v.errorf(path, el, "%v", err)
+		return
+	}
+	if err := v.value(t, text); err != nil {
+		v.errorf(path, el, "%v", err)
+	}
+}
+
+func (v *validator) complexType(path string, t *ComplexType, el *xmltree.Element) {
+	v.attributes(path, t, el)
+	if base, ok := simpleContentType(t); ok {
+		if len(el.Children) > 0 {
+			v.errorf(path, el, "type %s has simple content and may not contain elements",
+				t.Name.Local)
+			return
+		}
+		v.simpleContent(path, base, el)
+		return
+	}
+
+	elements := contentElements(t, 0)
+	model := contentModel(t, 0)
+	if model == nil && len(elements) > 0 {
+		v.flatContent(path, elements, el)
+		return
+	}
+	m := matcher{
+		elements: elements,
+		children: el.Children,
+		decls:    make([]*Element, len(el.Children)),
+		missing:  -1,
+	}
+	// Position of the first child that does not fit the content
+	// model, or -1 if required elements are missing.
+	end := 0
+	if model != nil {
+		var ok bool
+		if end, ok = m.match(model, 0); !ok {
+			end = -1
+		}
+	}
+	for i := range el.Children {
+		child := &el.Children[i]
+		childPath := path + "/" + child.Name.Local
+		if i == end {
+			v.errorf(childPath, child, "unexpected element %s in %s",
+				child.Name.Local, el.Name.Local)
+		}
+		decl := m.decls[i]
+		if decl == nil {
+			decl = m.lookup(child.Name)
+		}
+		if decl != nil {
+			v.element(childPath, decl, child)
+		}
+	}
+	if end < 0 {
+		v.errorf(path, el, "missing required element %s", m.expected.Local)
+	}
+}
+
+// flatContent validates the children of an element against a list of
+// element declarations, when the content model of the type is not
+// known.
+func (v *validator) flatContent(path string, elements []Element, el *xmltree.Element) {
+	var (
+		count    = make([]int, len(elements))
+		last     = -1
+		wildcard = false
+	)
+	for _, e := range elements {
+		wildcard = wildcard || e.Wildcard
+	}
+	for i := range el.Children {
+		child := &el.Children[i]
+		childPath := path + "/" + child.Name.Local
+		idx := -1
+		for j, e := range elements {
+			if !e.Wildcard && e.instance == child.Name {
+				idx = j
+				break
+			}
+		}
+		if idx < 0 {
+			if !wildcard {
+				v.errorf(childPath, child, "unexpected element %s in %s",
+					child.Name.Local, el.Name.Local)
+			}
+			continue
+		}
+		if idx < last {
+			v.errorf(childPath, child, "element %s must come before %s",
+				elements[last].Name.Local, child.Name.Local)
+		}
+		last = idx
+		count[idx]++
+		v.element(childPath, &elements[idx], child)
+	}
+	for i, e := range elements {
+		if e.Wildcard {
+			continue
+		}
+		if count[i] == 0 && !e.Optional {
+			v.errorf(path, el, "missing required element %s", e.Name.Local)
+		} else if count[i] > 1 && !e.Plural {
+			v.errorf(path, el, "element %s may only appear once, found %d",
+				e.Name.Local, count[i])
+		}
+	}
+}
+
+// A matcher assigns the children of an element to the particles of a
+// content model. Matching is greedy; each particle consumes as many
+// children as it can, and alternatives of a choice are tried in order
+// without backtracking.
+type matcher struct {
+	elements []Element
+	children []xmltree.Element
+	// The declaration matched by each child, if any
+	decls []*Element
+	// The furthest position at which a required element was not
+	// found, and the name of that element.
+	missing  int
+	expected xml.Name
+}
+
+func (m *matcher) lookup(name xml.Name) *Element {
+	for i, e := range m.elements {
+		if !e.Wildcard && e.instance == name {
+			return &m.elements[i]
+		}
+	}
+	return nil
+}
+
+// match matches as many occurrences of p as possible, starting at
+// the child at position pos. It returns the position of the first
+// child not consumed, and whether p occurred at least MinOccurs times.
+func (m *matcher) match(p *Particle, pos int) (int, bool) {
+	count := 0
+	for p.MaxOccurs < 0 || count < p.MaxOccurs {
+		next, ok := m.matchOnce(p, pos)
+		if !ok {
+			break
+		}
+		if next == pos {
+			// The particle's content is emptiable, so any
+			// remaining occurrences can be empty, too.
+			count = p.MinOccurs
+			break
+		}
+		pos = next
+		count++
+	}
+	if count < p.MinOccurs && p.Kind == ElementParticle && pos > m.missing {
+		m.missing, m.expected = pos, p.Name
+	}
+	return pos, count >= p.MinOccurs
+}
+
+func (m *matcher) matchOnce(p *Particle, pos int) (int, bool) {
+	switch p.Kind {
+	case ElementParticle:
+		if pos < len(m.children) {
+			if decl := m.lookup(m.children[pos].Name); decl != nil && decl.Name == p.Name {
+				m.decls[pos] = decl
+				return pos + 1, true
+			}
+		}
+	case WildcardParticle:
+		// Children matching one of the declared elements are
+		// left to their declarations.
+		if pos < len(m.children) && m.lookup(m.children[pos].Name) == nil {
+			return pos + 1, true
+		}
+	case SequenceParticle:
+		for i := range p.Particles {
+			var ok bool
+			if pos, ok = m.match(&p.Particles[i], pos); !ok {
+				return pos, false
+			}
+		}
+		return pos, true
+	case ChoiceParticle:
+		empty := false
+		for i := range p.Particles {
+			next, ok := m.match(&p.Particles[i], pos)
+			if ok && next > pos {
+				return next, true
+			}
+			empty = empty || ok
+		}
+		return pos, empty
+	case AllParticle:
+		seen := make([]bool, len(p.Particles))
+		for progress := true; progress; {
+			progress = false
+			for i := range p.Particles {
+				if seen[i] {
+					continue
+				}
+				if next, ok := m.match(&p.Particles[i], pos); ok && next > pos {
+					seen[i], pos, progress = true, next, true
+				}
+			}
+		}
+		for i := range p.Particles {
+			if !seen[i] {
+				if _, ok := m.match(&p.Particles[i], pos); !ok {
+					return pos, false
+				}
+			}
+		}
+		return pos, true
+	}
+	return pos, false
+}
+
+func (v *validator) attributes(path string, t *ComplexType, el *xmltree.Element) {
+	for _, a := range contentAttributes(t, 0) {
+		found := false
+		for _, attr := range el.StartElement.Attr {
+			if attr.Name != a.instance {
+				continue
+			}
+			found = true
+			if err := v.value(a.Type, attr.Value); err != nil {
+				v.errorf(path, el, "attribute %s: %v", a.Name.Local, err)
+			}
+			break
+		}
+		if !found && !a.Optional {
+			v.errorf(path, el, "missing required attribute %s", a.Name.Local)
+		}
+	}
+}
+
+// contentElements returns the elements allowed in a complex type,
+// including those inherited from the types it extends.
+func contentElements(t *ComplexType, depth int) []Element {
+	const maxDepth = 1000
+	base, ok := t.Base.(*ComplexType)
+	if !ok || depth > maxDepth {
+		return t.Elements
+	}
+	if t.Extends {
+		inherited := contentElements(base, depth+1)
+		return append(inherited[:len(inherited):len(inherited)], t.Elements...)
+	}
+	// Wildcards are not removed by restriction
+	for _, el := range t.Elements {
+		if el.Wildcard {
+			return t.Elements
+		}
+	}
+	for _, el := range base.Elements {
+		if el.Wildcard {
+			return append(t.Elements[:len(t.Elements):len(t.Elements)], el)
+		}
+	}
+	return t.Elements
+}
+
+// contentModel returns the content model of a complex type, including
+// the content models of the types it extends.
+func contentModel(t *ComplexType, depth int) *Particle {
+	const maxDepth = 1000
+	base, ok := t.Base.(*ComplexType)
+	if !ok || !t.Extends || depth > maxDepth {
+		return t.Content
+	}
+	inherited := contentModel(base, depth+1)
+	if inherited == nil {
+		return t.Content
+	} else if t.Content == nil {
+		return inherited
+	}
+	return &Particle{
+		Kind:      SequenceParticle,
+		MinOccurs: 1,
+		MaxOccurs: 1,
+		Particles: []Particle{*inherited, *t.Content},
+	}
+}
+
+// contentAttributes returns the attributes allowed in a complex type,
+// including those inherited from its base types.
+func contentAttributes(t *ComplexType, depth int) []Attribute {
+	const maxDepth = 1000
+	base, ok := t.Base.(*ComplexType)
+	if !ok || depth > maxDepth {
+		return t.Attributes
+	}
+	result := t.Attributes[:len(t.Attributes):len(t.Attributes)]
+Loop:
+	for _, b := range contentAttributes(base, depth+1) {
+		for _, a := range t.Attributes {
+			if a.Name.Local == b.Name.Local {
+				continue Loop
+			}
+		}
+		result = append(result, b)
+	}
+	return result
+}
+
+// simpleContentType returns the simple type describing the character
+// data of a complex type with simple content.
+func simpleContentType(t *ComplexType) (Type, bool) {
+	const maxDepth = 1000
+	var base Type = t
+	for i := 0; i < maxDepth; i++ {
+		c, ok := base.(*ComplexType)
+		if !ok {
+			break
+		}
+		if len(c.Elements) > 0 {
+			return nil, false
+		}
+		base = c.Base
+	}
+	switch b := base.(type) {
+	case *SimpleType:
+		return b, true
+	case Builtin:
+		return b, b != AnyType
+	}
+	return nil, false
+}
+
+// value checks that s is a valid lexical representation of a value
+// of type t.
+func (v *validator) value(t Type, s string) error {
+	switch t := t.(type) {
+	case Builtin:
+		return checkBuiltin(t, s)
+	case *SimpleType:
+		return v.simpleType(t, s, 0)
+	case *ComplexType:
+		if base, ok := simpleContentType(t); ok {
+			return v.value(base, s)
+		}
+	}
+	return nil
+}
+
+func (v *validator) simpleType(t *SimpleType, s string, depth int) error {
+	const maxDepth = 1000
+	if depth > maxDepth {
+		return nil
+	}
+	if len(t.Union) > 0 {
+		for _, member := range t.Union {
+			if v.value(member, s) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a valid %s", s, t.Name.Local)
+	}
+	if t.List {
+		items := strings.Fields(s)
+		for _, item := range items {
+			if err := v.value(t.Base, item); err != nil {
+				return err
+			}
+		}
+		return v.facets(t, s, len(items))
+	}
+	var err error
+	switch base := t.Base.(type) {
+	case *SimpleType:
+		err = v.simpleType(base, s, depth+1)
+	case nil:
+	default:
+		err = v.value(base, s)
+	}
+	if err != nil {
+		return err
+	}
+	if isList(t, 0) {
+		return v.facets(t, s, len(strings.Fields(s)))
+	}
+	return v.facets(t, s, -1)
+}
+
+func isList(t Type, depth int) bool {
+	const maxDepth = 1000
+	if depth > maxDepth {
+		return false
+	}
+	switch t := t.(type) {
+	case *SimpleType:
+		return t.List || isList(t.Base, depth+1)
+	case Builtin:
+		switch t {
+		case ENTITIES, IDREFS, NMTOKENS:
+			return true
+		}
+	}
+	return false
+}
+
+func builtinBase(t Type) Builtin {
+	const maxDepth = 1000
+	for i := 0; i < maxDepth && t != nil; i++ {
+		if b, ok := t.(Builtin); ok {
+			return b
+		}
+		t = Base(t)
+	}
+	return AnySimpleType
+}
+
+// facets checks the restrictions of t against s. If t is a list
+// type, items is the number of items in s.
+func (v *validator) facets(t *SimpleType, s string, items int) error {
+	r := &t.Restriction
+	base := builtinBase(t)
+	if base != String && base != NormalizedString && base != AnySimpleType {
+		s = strings.TrimSpace(s)
+	}
+	if len(r.Enum) > 0 {
+		found := false
+		for _, e := range r.Enum {
+			if e == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%q is not one of the allowed values of %s: %s",
+				s, t.Name.Local, strings.Join(r.Enum, ", "))
+		}
+	}
+	if r.Pattern != nil && !v.anchored(r.Pattern).MatchString(s) {
+		return fmt.Errorf("%q does not match pattern %s", s, r.Pattern)
+	}
+
+	length := items
+	if length < 0 {
+		switch base {
+		case HexBinary:
+			length = len(s) / 2
+		case Base64Binary:
+			if b, err := base64.StdEncoding.DecodeString(collapse(s)); err == nil {
+				length = len(b)
+			}
+		default:
+			length = utf8.RuneCountInString(s)
+		}
+	}
+	if r.HasLength && length != r.Length {
+		return fmt.Errorf("length of %q must be %d", s, r.Length)
+	}
+	if r.HasMinLength && length < r.MinLength {
+		return fmt.Errorf("length of %q must be at least %d", s, r.MinLength)
+	}
+	if r.HasMaxLength && length > r.MaxLength {
+		return fmt.Errorf("length of %q must be at most %d", s, r.MaxLength)
+	}
+	if items >= 0 {
+		return nil
+	}
+
+	if isNumeric(base) {
+		if r.HasMin || r.HasMax {
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", s)
+			}
+			if r.HasMin && r.MinExclusive && n <= r.Min {
+				return fmt.Errorf("%s is not greater than the exclusive minimum %v", s, r.Min)
+			} else if r.HasMin && n < r.Min {
+				return fmt.Errorf("%s is less than the minimum %v", s, r.Min)
+			}
+			if r.HasMax && r.MaxExclusive && n >= r.Max {
+				return fmt.Errorf("%s is not less than the exclusive maximum %v", s, r.Max)
+			} else if r.HasMax && n > r.Max {
+				return fmt.Errorf("%s is greater than the maximum %v", s, r.Max)
+			}
+		}
+		intDigits, fracDigits := countDigits(s)
+		if r.TotalDigits != 0 && intDigits+fracDigits > r.TotalDigits {
+			return fmt.Errorf("%s has more than %d digits", s, r.TotalDigits)
+		}
+		if r.Precision != 0 && fracDigits > r.Precision {
+			return fmt.Errorf("%s has more than %d fraction digits", s, r.Precision)
+		}
+	}
+	if !r.MinDate.IsZero() || !r.MaxDate.IsZero() {
+		d, err := parseDateValue(base, s)
+		if err != nil {
+			return err
+		}
+		if !r.MinDate.IsZero() && r.MinExclusive && !d.After(r.MinDate) {
+			return fmt.Errorf("%s is not after the exclusive minimum %s", s, r.MinDate.Format(time.RFC3339))
+		} else if !r.MinDate.IsZero() && d.Before(r.MinDate) {
+			return fmt.Errorf("%s is before the minimum %s", s, r.MinDate.Format(time.RFC3339))
+		}
+		if !r.MaxDate.IsZero() && r.MaxExclusive && !d.Before(r.MaxDate) {
+			return fmt.Errorf("%s is not before the exclusive maximum %s", s, r.MaxDate.Format(time.RFC3339))
+		} else if !r.MaxDate.IsZero() && d.After(r.MaxDate) {
+			return fmt.Errorf("%s is after the maximum %s", s, r.MaxDate.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// XML Schema patterns are implicitly anchored at both ends.
+func (v *validator) anchored(pat *regexp.Regexp) *regexp.Regexp {
+	if reg, ok := v.patterns[pat]; ok {
+		return reg
+	}
+	reg, err := regexp.Compile("^(?:" + pat.String() + ")$")
+	if err != nil {
+		reg = pat
+	}
+	v.patterns[pat] = reg
+	return reg
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// countDigits returns the number of significant digits before and
+// after the decimal point of a decimal number.
+func countDigits(s string) (intDigits, fracDigits int) {
+	s = strings.TrimLeft(s, "+-")
+	parts := strings.SplitN(s, ".", 2)
+	intPart := strings.TrimLeft(parts[0], "0")
+	intDigits = len(intPart)
+	if len(parts) == 2 {
+		fracDigits = len(strings.TrimRight(parts[1], "0"))
+	}
+	if intDigits == 0 && fracDigits == 0 {
+		intDigits = 1
+	}
+	return intDigits, fracDigits
+}
+
+func isNumeric(b Builtin) bool {
+	switch b {
+	case Byte, Decimal, Double, Float, Int, Integer, Long,
+		NegativeInteger, NonNegativeInteger, NonPositiveInteger,
+		PositiveInteger, Short, UnsignedByte, UnsignedInt,
+		UnsignedLong, UnsignedShort:
+		return true
+	}
+	return false
+}
+
+func parseBoolValue(s string) bool {
+	switch strings.TrimSpace(s) {
+	case "true", "1":
+		return true
+	}
+	return false
+}
+
+var (
+	decimalPattern  = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+	floatPattern    = regexp.MustCompile(`^([+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?|-?INF|NaN)$`)
+	durationPattern = regexp.MustCompile(`^-?P(([0-9]+Y)?([0-9]+M)?([0-9]+D)?(T([0-9]+H)?([0-9]+M)?([0-9]+(\.[0-9]+)?S)?)?)$`)
+	timezone        = `(Z|[+-][0-9]{2}:[0-9]{2})?`
+	datePatterns    = map[Builtin]*regexp.Regexp{
+		Date:       regexp.MustCompile(`^-?[0-9]{4,}-[0-9]{2}-[0-9]{2}` + timezone + `$`),
+		DateTime:   regexp.MustCompile(`^-?[0-9]{4,}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?` + timezone + `$`),
+		Time:       regexp.MustCompile(`^[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?` + timezone + `$`),
+		GDay:       regexp.MustCompile(`^---[0-9]{2}` + timezone + `$`),
+		GMonth:     regexp.MustCompile(`^--[0-9]{2}` + timezone + `$`),
+		GMonthDay:  regexp.MustCompile(`^--[0-9]{2}-[0-9]{2}` + timezone + `$`),
+		GYear:      regexp.MustCompile(`^-?[0-9]{4,}` + timezone + `$`),
+		GYearMonth: regexp.MustCompile(`^-?[0-9]{4,}-[0-9]{2}` + timezone + `$`),
+	}
+)
+
+func parseDateValue(b Builtin, s string) (time.Time, error) {
+	var formats []string
+	switch b {
+	case Date:
+		formats = []string{"2006-01-02Z07:00", "2006-01-02"}
+	case DateTime:
+		formats = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"}
+	default:
+		return time.Time{}, fmt.Errorf("cannot compare %s value %q to a date", b.Name().Local, s)
+	}
+	var err error
+	for _, f := range formats {
+		var t time.Time
+		if t, err = time.Parse(f, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a valid %s", s, b.Name().Local)
+}
+
+// checkBuiltin validates the lexical space of the built-in types.
+func checkBuiltin(b Builtin, s string) error {
+	invalid := fmt.Errorf("%q is not a valid %s", s, b.Name().Local)
+	switch b {
+	case String, NormalizedString, AnyType, AnySimpleType:
+		return nil
+	}
+	s = strings.TrimSpace(s)
+	switch b {
+	case Boolean:
+		switch s {
+		case "true", "false", "1", "0":
+			return nil
+		}
+		return invalid
+	case Decimal:
+		if !decimalPattern.MatchString(s) {
+			return invalid
+		}
+	case Float, Double:
+		if !floatPattern.MatchString(s) {
+			return invalid
+		}
+	case Byte, Short, Int, Long, UnsignedByte, UnsignedShort, UnsignedInt, UnsignedLong:
+		bits := map[Builtin]int{
+			Byte: 8, Short: 16, Int: 32, Long: 64,
+			UnsignedByte: 8, UnsignedShort: 16, UnsignedInt: 32, UnsignedLong: 64,
+		}[b]
+		var err error
+		switch b {
+		case Byte, Short, Int, Long:
+			_, err = strconv.ParseInt(strings.TrimPrefix(s, "+"), 10, bits)
+		default:
+			_, err = strconv.ParseUint(strings.TrimPrefix(s, "+"), 10, bits)
+		}
+		if err != nil {
+			return invalid
+		}
+	case Integer, NegativeInteger, NonNegativeInteger, NonPositiveInteger, PositiveInteger:
+		n, ok := new(big.Int).SetString(strings.TrimPrefix(s, "+"), 10)
+		if !ok {
+			return invalid
+		}
+		sign := n.Sign()
+		if b == NegativeInteger && sign >= 0 ||
+			b == NonNegativeInteger && sign < 0 ||
+			b == NonPositiveInteger && sign > 0 ||
+			b == PositiveInteger && sign <= 0 {
+			return invalid
+		}
+	case Date, DateTime, Time, GDay, GMonth, GMonthDay, GYear, GYearMonth:
+		if !datePatterns[b].MatchString(s) {
+			return invalid
+		}
+	case Duration:
+		if !durationPattern.MatchString(s) || strings.HasSuffix(s, "P") || strings.HasSuffix(s, "T") {
+			return invalid
+		}
+	case HexBinary:
+		if _, err := hex.DecodeString(s); err != nil {
+			return invalid
+		}
+	case Base64Binary:
+		if _, err := base64.StdEncoding.DecodeString(collapse(s)); err != nil {
+			return invalid
+		}
+	case QName:
+		parts := strings.Split(s, ":")
+		if len(parts) > 2 || s == "" {
+			return invalid
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package xsd
+
+import (
+	"strings"
+	"testing"
+
+	"aqwari.net/xml/xmltree"
+)
+
+var validateSchema = []byte(`
+<schema xmlns="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:orders"
+        targetNamespace="urn:orders" elementFormDefault="qualified">
+  <simpleType name="sku">
+    <restriction base="string">
+      <pattern value="[A-Z]{3}-[0-9]{4}" />
+    </restriction>
+  </simpleType>
+  <simpleType name="status">
+    <restriction base="string">
+      <enumeration value="open" />
+      <enumeration value="closed" />
+    </restriction>
+  </simpleType>
+  <simpleType name="quantity">
+    <restriction base="int">
+      <minInclusive value="1" />
+      <maxInclusive value="100" />
+    </restriction>
+  </simpleType>
+  <simpleType name="price">
+    <restriction base="decimal">
+      <totalDigits value="6" />
+      <fractionDigits value="2" />
+    </restriction>
+  </simpleType>
+  <simpleType name="discount">
+    <restriction base="decimal">
+      <minInclusive value="0" />
+      <maxExclusive value="10" />
+    </restriction>
+  </simpleType>
+  <simpleType name="note">
+    <restriction base="string">
+      <maxLength value="10" />
+    </restriction>
+  </simpleType>
+  <simpleType name="empty">
+    <restriction base="string">
+      <maxLength value="0" />
+    </restriction>
+  </simpleType>
+  <complexType name="line">
+    <sequence>
+      <element name="sku" type="tns:sku" />
+      <element name="quantity" type="tns:quantity" />
+      <element name="price" type="tns:price" />
+    </sequence>
+  </complexType>
+  <complexType name="order">
+    <sequence>
+      <element name="note" type="tns:note" minOccurs="0" />
+      <element name="line" type="tns:line" maxOccurs="unbounded" />
+      <choice minOccurs="0">
+        <element name="rush" type="boolean" />
+        <element name="deliverBy" type="date" />
+      </choice>
+    </sequence>
+    <attribute name="id" type="int" use="required" />
+    <attribute name="status" type="tns:status" />
+    <attribute name="discount" type="tns:discount" />
+    <attribute name="reserved" type="tns:empty" />
+  </complexType>
+  <element name="order" type="tns:order" />
+</schema>
+`)
+
+func TestValidate(t *testing.T) {
+	schema, err := Parse(validateSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		doc    string
+		errors []string
+	}{
+		{
+			doc: `<order xmlns="urn:orders" id="1" status="open" discount="0">
+			  <note>rush</note>
+			  <line><sku>ABC-1234</sku><quantity>2</quantity><price>10.50</price></line>
+			  <line><sku>XYZ-0001</sku><quantity>100</quantity><price>1234.5</price></line>
+			</order>`,
+		},
+		{
+			doc: `<order xmlns="urn:orders" status="pending">
+			  <line><sku>abc</sku><quantity>0</quantity><price>1.234</price></line>
+			</order>`,
+			errors: []string{
+				`/order (line 1, column 1): missing required attribute id`,
+				`/order (line 1, column 1): attribute status: "pending" is not one of the allowed values`,
+				`/order/line/sku (line 2, column 12): "abc" does not match pattern`,
+				`/order/line/quantity (line 2, column 26): 0 is less than the minimum 1`,
+				`/order/line/price (line 2, column 48): 1.234 has more than 2 fraction digits`,
+			},
+		},
+		{
+			doc: `<order xmlns="urn:orders" id="x">
+			  <line><sku>ABC-1234</sku><price>1</price></line>
+			  <note>much too long a note</note>
+			</order>`,
+			errors: []string{
+				`/order (line 1, column 1): attribute id: "x" is not a valid int`,
+				`/order/line (line 2, column 6): missing required element quantity`,
+				`/order/note (line 3, column 6): unexpected element note in order`,
+				`/order/note (line 3, column 6): length of "much too long a note" must be at most 10`,
+			},
+		},
+		{
+			doc: `<order xmlns="urn:orders" id="2">
+			  <line><sku>ABC-1234</sku><quantity>2</quantity><price>10.50</price></line>
+			  <rush>yes</rush>
+			  <deliverBy>2020-01-01</deliverBy>
+			</order>`,
+			errors: []string{
+				`/order/rush (line 3, column 6): "yes" is not a valid boolean`,
+				`/order/deliverBy (line 4, column 6): unexpected element deliverBy in order`,
+			},
+		},
+		{
+			doc:    `<order xmlns="urn:orders" id="3"><note>empty</note><extra /></order>`,
+			errors: []string{`/order (line 1, column 1): missing required element line`},
+		},
+		{
+			doc: `<order xmlns="urn:orders" id="4">
+			  <line><sku>ABC-1234</sku><quantity>2</quantity><price>10.50</price></line>
+			  <extra />
+			</order>`,
+			errors: []string{`/order/extra (line 3, column 6): unexpected element extra in order`},
+		},
+		{
+			doc: `<order xmlns="urn:orders" id="5" discount="-5">
+			  <line><sku>ABC-1234</sku><quantity>2</quantity><price>10.50</price></line>
+			</order>`,
+			errors: []string{`/order (line 1, column 1): attribute discount: -5 is less than the minimum 0`},
+		},
+		{
+			doc: `<order xmlns="urn:orders" id="6" discount="10">
+			  <line><sku>ABC-1234</sku><quantity>2</quantity><price>10.50</price></line>
+			</order>`,
+			errors: []string{`/order (line 1, column 1): attribute discount: 10 is not less than the exclusive maximum 10`},
+		},
+		{
+			doc: `<order xmlns="urn:orders" id="7" reserved="">
+			  <line><sku>ABC-1234</sku><quantity>2</quantity><price>10.50</price></line>
+			</order>`,
+		},
+		{
+			doc: `<order xmlns="urn:orders" id="7" reserved="x">
+			  <line><sku>ABC-1234</sku><quantity>2</quantity><price>10.50</price></line>
+			</order>`,
+			errors: []string{`/order (line 1, column 1): attribute reserved: length of "x" must be at most 0`},
+		},
+		{
+			doc:    `<order xmlns="urn:wrong" id="7" />`,
+			errors: []string{`/order (line 1, column 1): no declaration for root element order`},
+		},
+		{
+			doc: `<o:order xmlns:o="urn:orders" o:id="8">
+			  <line><sku>ABC-1234</sku><quantity>2</quantity><price>10.50</price></line>
+			</o:order>`,
+			errors: []string{
+				`/order (line 1, column 1): missing required attribute id`,
+				`/order (line 1, column 1): missing required element line`,
+			},
+		},
+		{
+			doc:    `<invoice xmlns="urn:orders" />`,
+			errors: []string{`/invoice (line 1, column 1): no declaration for root element invoice`},
+		},
+	}
+	for i, tt := range tests {
+		doc, err := xmltree.Parse([]byte(tt.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs := Validate(schema, doc)
+		if len(errs) != len(tt.errors) {
+			t.Errorf("document %d: got %d errors, wanted %d", i, len(errs), len(tt.errors))
+			for _, err := range errs {
+				t.Logf("%v", err)
+			}
+			continue
+		}
+		for j, err := range errs {
+			if !strings.HasPrefix(err.Error(), tt.errors[j]) {
+				t.Errorf("document %d: got error %q, wanted %q", i, err, tt.errors[j])
+			}
+		}
+	}
+}
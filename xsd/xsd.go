@@ -2,15 +2,25 @@
 //
 // The xsd package implements a parser for a subset of the XML Schema
 // standard. This package is intended for use in code-generation programs for
-// client libraries, and as such, does not validate XML Schema documents,
-// nor does it provide sufficient information to validate the documents
-// described by a schema. Notably, the xsd package does not preserve
-// information about element or attribute groups. Instead, all groups
-// are de-referenced before parsing is done, and all nested sequences of
-// elements are flattened.
+// client libraries, and as such, does not validate XML Schema documents.
+// The Validate function can check an instance document against the
+// parsed schema, within the limits of the information the xsd package
+// records. Element and attribute groups are de-referenced before parsing
+// is done, and all nested sequences of elements are flattened into a
+// list of elements. The structure of nested <sequence>, <choice> and
+// <all> groups is kept separately as a tree of Particles. The named
+// groups of a schema are recorded in its Groups and AttributeGroups,
+// and each Element or Attribute that was copied from a group refers
+// to it by name. The top-level elements of a schema, any of which
+// may be the root of a document, are recorded in its Elements.
 //
 // The xsd package respects XML name spaces in schema documents, and can
 // parse schema documents that import or include other schema documents.
+// The documents that share a target namespace are parsed into a single
+// Schema.
+// The ReadAll function retrieves a schema document along with the
+// documents it references, through a SchemaResolver. A Catalog can
+// direct a SchemaResolver to local copies of remote schema documents.
 package xsd // import "aqwari.net/xml/xsd"
 
 import (
@@ -57,6 +67,10 @@ type Element struct {
 	// An abstract type does not appear in the xml document, but
 	// is "implemented" by other types in its substitution group.
 	Abstract bool
+	// The head of the substitution group this element belongs to,
+	// if any. Any element in a substitution group may appear in
+	// place of the group's head.
+	SubstitutionGroup xml.Name
 	// True if maxOccurs > 1 or maxOccurs == "unbounded"
 	Plural bool
 	// True if the element is optional.
@@ -65,10 +79,20 @@ type Element struct {
 	Nillable bool
 	// Default overrides the zero value of this element.
 	Default string
+	// If not empty, the only value this element may have. Like
+	// Default, it is the value of an empty element.
+	Fixed string
+	// If the element was declared in a named <group> that was
+	// referenced by the enclosing type or group, the name of that
+	// group.
+	Group xml.Name
 	// Any additional attributes provided in the <xs:element> element.
 	Attr []xml.Attr
 	// Used for resolving prefixed strings in extra attribute values.
 	xmltree.Scope
+	// The name of the element in instance documents, which has
+	// no namespace if the element is local and unqualified.
+	instance xml.Name
 }
 
 // An Attribute describes the key=value pairs that may appear within the
@@ -88,28 +112,78 @@ type Attribute struct {
 	Plural bool
 	// Default overrides the zero value of this element.
 	Default string
+	// If not empty, the only value this attribute may have. Like
+	// Default, it is the value of a missing attribute.
+	Fixed string
 	// True if the attribute is not required
 	Optional bool
+	// If the attribute was declared in a named <attributeGroup> that
+	// was referenced by the enclosing type or group, the name of that
+	// group.
+	Group xml.Name
 	// Any additional attributes provided in the <xs:attribute> element.
 	Attr []xml.Attr
 	// Used for resolving qnames in additional attributes.
 	xmltree.Scope
+	// The name of the attribute in instance documents, which has
+	// no namespace if the attribute is local and unqualified.
+	instance xml.Name
 }
 
 // A Schema is the decoded form of an XSD <schema> element. It contains
-// a collection of all types declared in the schema. Top-level elements
-// are not recorded in a Schema.
+// a collection of all types declared in the schema, and of the
+// top-level elements that may be the root of a document.
 type Schema struct {
 	// The Target namespace of the schema. All types defined in this
 	// schema will be in this name space.
 	TargetNS string `xml:"targetNamespace,attr"`
 	// Types defined in this schema declaration
 	Types map[xml.Name]Type
+	// Top-level elements declared in this schema, including
+	// abstract elements.
+	Elements map[xml.Name]*Element
+	// Named groups of elements defined in this schema.
+	Groups map[xml.Name]*Group
+	// Named groups of attributes defined in this schema.
+	AttributeGroups map[xml.Name]*AttributeGroup
 	// Any annotations declared at the top-level of the schema, separated
 	// by new lines.
 	Doc string
 }
 
+// A Group is a named group of elements, declared with <xs:group>,
+// that can be included in the content of complex types and other
+// groups. The Elements of a Group include those of any groups it
+// refers to.
+//
+// http://www.w3.org/TR/2004/REC-xmlschema-1-20041028/structures.html#element-group
+type Group struct {
+	// Annotations provided by the schema author.
+	Doc string
+	// The canonical name of this group.
+	Name xml.Name
+	// XML elements that may appear in the group.
+	Elements []Element
+	// The order and number of times the members of Elements
+	// may appear.
+	Content *Particle
+}
+
+// An AttributeGroup is a named group of attributes, declared with
+// <xs:attributeGroup>, that can be included in complex types and
+// other attribute groups.
+//
+// http://www.w3.org/TR/2004/REC-xmlschema-1-20041028/structures.html#element-attributeGroup
+type AttributeGroup struct {
+	// Annotations provided by the schema author.
+	Doc string
+	// The canonical name of this attribute group.
+	Name xml.Name
+	// The attributes in the group, including those of any
+	// attribute groups it refers to.
+	Attributes []Attribute
+}
+
 // FindType looks for a type by its canonical name. In addition to the types
 // declared in a Schema, FindType will also search through the types that
 // a Schema's top-level types are derived from. FindType will return nil if
@@ -160,6 +234,10 @@ type ComplexType struct {
 	Anonymous bool
 	// XML elements that this type may contain in its content.
 	Elements []Element
+	// The content model of this type; the order and number of times
+	// the members of Elements may appear. Content is nil if the type
+	// does not declare any elements of its own.
+	Content *Particle
 	// Possible attributes for the element's opening tag.
 	Attributes []Attribute
 	// An abstract type does not appear in the xml document, but
@@ -176,6 +254,50 @@ type ComplexType struct {
 
 func (*ComplexType) isType() {}
 
+// A ParticleKind identifies the role of a Particle in a content model.
+type ParticleKind int
+
+const (
+	// A single element, named by the Particle's Name field.
+	ElementParticle ParticleKind = iota
+	// An <xs:any> wildcard element.
+	WildcardParticle
+	// Particles that must appear in order.
+	SequenceParticle
+	// Exactly one of a set of Particles.
+	ChoiceParticle
+	// Particles that may appear in any order.
+	AllParticle
+)
+
+// A Particle is a node in the content model of a complex type. Particles
+// of kind SequenceParticle, ChoiceParticle and AllParticle contain other
+// Particles, while ElementParticles refer to one of the complex type's
+// Elements by name.
+//
+// http://www.w3.org/TR/2004/REC-xmlschema-1-20041028/structures.html#cParticles
+type Particle struct {
+	Kind ParticleKind
+	// The minimum and maximum number of times this particle may
+	// occur. MaxOccurs is -1 if the particle is unbounded.
+	MinOccurs, MaxOccurs int
+	// For ElementParticles, the canonical name of the element,
+	// matching the Name of an Element in the enclosing ComplexType.
+	Name xml.Name
+	// Members of a sequence, choice, or all group.
+	Particles []Particle
+}
+
+// Optional returns true if a Particle may be omitted.
+func (p *Particle) Optional() bool {
+	return p.MinOccurs == 0
+}
+
+// Plural returns true if a Particle may occur more than once.
+func (p *Particle) Plural() bool {
+	return p.MaxOccurs < 0 || p.MaxOccurs > 1
+}
+
 // A SimpleType describes an XML element that does not contain elements
 // or attributes. SimpleTypes are suitable for use as attribute values.
 // A SimpleType can be an "atomic" type (int, string, etc), or a list of
@@ -225,12 +347,19 @@ type Restriction struct {
 	// If len(Enum) > 0, the type must be one of the values contained
 	// in Enum.
 	Enum []string
-	// The minimum and maximum (exclusive) value of this type, if
-	// numeric
-	Min, Max float64
-	// Exact, maximum and minimum length (in characters) of this type
-	Length, MinLength, MaxLength int
-	MinDate, MaxDate             time.Time
+	// The minimum and maximum value of this type, if numeric.
+	// HasMin and HasMax report whether each bound is set.
+	Min, Max       float64
+	HasMin, HasMax bool
+	// True if the minimum or maximum value, numeric or date, is
+	// excluded from the values of this type.
+	MinExclusive, MaxExclusive bool
+	// Exact, maximum and minimum length (in characters) of this type.
+	// HasLength, HasMinLength and HasMaxLength report whether each
+	// facet is set.
+	Length, MinLength, MaxLength          int
+	HasLength, HasMinLength, HasMaxLength bool
+	MinDate, MaxDate                      time.Time
 	// Regular expression that values of this type must match
 	Pattern *regexp.Regexp
 	// The exact number of digits allowed
@@ -227,3 +227,285 @@ func TestCases(t *testing.T) {
 		}
 	}
 }
+
+func TestRedefine(t *testing.T) {
+	r := MapResolver{
+		"base.xsd": []byte(`
+			<schema xmlns="http://www.w3.org/2001/XMLSchema" xmlns:tns="tns" targetNamespace="tns">
+			  <complexType name="address">
+			    <sequence>
+			      <element name="street" type="string"/>
+			    </sequence>
+			  </complexType>
+			  <simpleType name="code">
+			    <restriction base="string"/>
+			  </simpleType>
+			</schema>`),
+		"other.xsd": []byte(`
+			<schema xmlns="http://www.w3.org/2001/XMLSchema" xmlns:tns="tns" targetNamespace="tns">
+			  <simpleType name="note">
+			    <restriction base="string"/>
+			  </simpleType>
+			</schema>`),
+		"main.xsd": []byte(`
+			<schema xmlns="http://www.w3.org/2001/XMLSchema" xmlns:tns="tns" targetNamespace="tns">
+			  <redefine schemaLocation="base.xsd">
+			    <complexType name="address">
+			      <complexContent>
+			        <extension base="tns:address">
+			          <sequence>
+			            <element name="country" type="string"/>
+			          </sequence>
+			        </extension>
+			      </complexContent>
+			    </complexType>
+			  </redefine>
+			  <override schemaLocation="base.xsd">
+			    <simpleType name="code">
+			      <restriction base="int"/>
+			    </simpleType>
+			    <simpleType name="note">
+			      <restriction base="int"/>
+			    </simpleType>
+			  </override>
+			</schema>`),
+	}
+	docs, err := ReadAll(r, "main.xsd", "other.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := Parse(docs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types map[xml.Name]Type
+	for _, s := range schema {
+		if s.TargetNS == "tns" {
+			if types != nil {
+				t.Fatal("documents with the same namespace were not merged")
+			}
+			types = s.Types
+		}
+	}
+	address, ok := types[xml.Name{"tns", "address"}].(*ComplexType)
+	if !ok {
+		t.Fatal("redefined type address not found")
+	}
+	if base, ok := address.Base.(*ComplexType); !ok || base.Name.Local != "_address_original" {
+		t.Errorf("address has base %s, want _address_original", XMLName(address.Base).Local)
+	} else if !base.Anonymous {
+		t.Error("original of redefined type address is not anonymous")
+	}
+	if _, ok := types[xml.Name{"tns", "_address_original"}]; ok {
+		t.Error("original of redefined type address is listed with the types")
+	}
+	if !address.Extends || len(address.Elements) != 1 || address.Elements[0].Name.Local != "country" {
+		t.Errorf("address does not extend the original with element country")
+	}
+	code, ok := types[xml.Name{"tns", "code"}].(*SimpleType)
+	if !ok {
+		t.Fatal("overridden type code not found")
+	}
+	if code.Base != Int {
+		t.Errorf("code has base %s, want int", XMLName(code.Base).Local)
+	}
+	if _, ok := types[xml.Name{"tns", "_code_original"}]; ok {
+		t.Error("overridden type code was kept")
+	}
+	// note is not declared in base.xsd, so its override is ignored,
+	// and the note type of other.xsd is kept.
+	note, ok := types[xml.Name{"tns", "note"}].(*SimpleType)
+	if !ok {
+		t.Fatal("type note of other.xsd not found")
+	}
+	if note.Base != String {
+		t.Errorf("note has base %s, want string", XMLName(note.Base).Local)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	schema, err := Parse([]byte(`
+		<schema xmlns="http://www.w3.org/2001/XMLSchema" xmlns:tns="tns" targetNamespace="tns">
+		  <group name="name">
+		    <sequence>
+		      <element name="given" type="string"/>
+		      <element name="family" type="string"/>
+		    </sequence>
+		  </group>
+		  <group name="contact">
+		    <sequence>
+		      <group ref="tns:name"/>
+		      <element name="email" type="string"/>
+		    </sequence>
+		  </group>
+		  <attributeGroup name="audit">
+		    <attribute name="version" type="int"/>
+		  </attributeGroup>
+		  <complexType name="person">
+		    <sequence>
+		      <group ref="tns:contact"/>
+		      <element name="age" type="int"/>
+		    </sequence>
+		    <attributeGroup ref="tns:audit"/>
+		  </complexType>
+		</schema>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s *Schema
+	for i := range schema {
+		if schema[i].TargetNS == "tns" {
+			s = &schema[i]
+		}
+	}
+	if s == nil {
+		t.Fatal("schema tns not found")
+	}
+	contact, ok := s.Groups[xml.Name{"tns", "contact"}]
+	if !ok {
+		t.Fatal("group contact not found")
+	}
+	groups := make(map[string]string)
+	for _, el := range contact.Elements {
+		groups[el.Name.Local] = el.Group.Local
+	}
+	if groups["given"] != "name" || groups["email"] != "" {
+		t.Errorf("members of group contact are attributed to groups %v", groups)
+	}
+	audit, ok := s.AttributeGroups[xml.Name{"tns", "audit"}]
+	if !ok || len(audit.Attributes) != 1 {
+		t.Fatal("attribute group audit not found")
+	}
+
+	person := s.Types[xml.Name{"tns", "person"}].(*ComplexType)
+	groups = make(map[string]string)
+	for _, el := range person.Elements {
+		groups[el.Name.Local] = el.Group.Local
+	}
+	for _, attr := range person.Attributes {
+		groups[attr.Name.Local] = attr.Group.Local
+	}
+	want := map[string]string{
+		"given":   "contact",
+		"family":  "contact",
+		"email":   "contact",
+		"age":     "",
+		"version": "audit",
+	}
+	for name, group := range want {
+		if got, ok := groups[name]; !ok {
+			t.Errorf("person has no member %s", name)
+		} else if got != group {
+			t.Errorf("member %s of person is from group %q, want %q", name, got, group)
+		}
+	}
+}
+
+func TestElements(t *testing.T) {
+	schema, err := Parse([]byte(`
+		<schema xmlns="http://www.w3.org/2001/XMLSchema" xmlns:tns="tns" xmlns:o="other" targetNamespace="tns">
+		  <import namespace="other"/>
+		  <element name="shape" type="tns:shape" abstract="true"/>
+		  <element name="circle" type="tns:circle" substitutionGroup="tns:shape"/>
+		  <element name="label" type="string" nillable="true"/>
+		  <element name="point" type="o:point"/>
+		  <complexType name="shape"/>
+		  <complexType name="circle">
+		    <complexContent>
+		      <extension base="tns:shape">
+		        <sequence>
+		          <element name="radius" type="double"/>
+		        </sequence>
+		      </extension>
+		    </complexContent>
+		  </complexType>
+		  <complexType name="drawing">
+		    <sequence>
+		      <element ref="tns:circle"/>
+		    </sequence>
+		  </complexType>
+		</schema>`), []byte(`
+		<schema xmlns="http://www.w3.org/2001/XMLSchema" targetNamespace="other">
+		  <complexType name="point"/>
+		</schema>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s *Schema
+	for i := range schema {
+		if schema[i].TargetNS == "tns" {
+			s = &schema[i]
+		}
+	}
+	if s == nil {
+		t.Fatal("schema tns not found")
+	}
+	if len(s.Elements) != 4 {
+		t.Errorf("schema has %d top-level elements, want 4", len(s.Elements))
+	}
+	shape, ok := s.Elements[xml.Name{"tns", "shape"}]
+	if !ok || !shape.Abstract {
+		t.Error("abstract element shape not found")
+	}
+	circle, ok := s.Elements[xml.Name{"tns", "circle"}]
+	if !ok {
+		t.Fatal("element circle not found")
+	}
+	if circle.SubstitutionGroup != (xml.Name{"tns", "shape"}) {
+		t.Errorf("circle has substitution group %v, want shape", circle.SubstitutionGroup)
+	}
+	if circle.Type != s.Types[xml.Name{"tns", "circle"}] {
+		t.Errorf("circle has type %v, want circle", XMLName(circle.Type))
+	}
+	if label, ok := s.Elements[xml.Name{"tns", "label"}]; !ok || !label.Nillable || label.Type != String {
+		t.Error("nillable string element label not found")
+	}
+	if _, ok := s.Elements[xml.Name{"tns", "point"}]; !ok {
+		t.Error("element point, of a type in another namespace, not found")
+	}
+	drawing := s.Types[xml.Name{"tns", "drawing"}].(*ComplexType)
+	if len(drawing.Elements) != 1 || drawing.Elements[0].SubstitutionGroup != circle.SubstitutionGroup {
+		t.Error("reference to circle does not keep its substitution group")
+	}
+}
+
+func TestFixed(t *testing.T) {
+	schema, err := Parse([]byte(`
+		<schema xmlns="http://www.w3.org/2001/XMLSchema" xmlns:tns="tns" targetNamespace="tns">
+		  <element name="version" type="string" fixed="1.0"/>
+		  <complexType name="order">
+		    <sequence>
+		      <element ref="tns:version"/>
+		      <element name="currency" type="string" default="USD"/>
+		      <element name="unit" type="string" fixed="kg"/>
+		    </sequence>
+		    <attribute name="schema" type="string" fixed="orders"/>
+		    <attribute name="priority" type="int" default="1"/>
+		  </complexType>
+		</schema>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := schema[0].Types[xml.Name{"tns", "order"}].(*ComplexType)
+	fixed := make(map[string]string)
+	for _, el := range order.Elements {
+		fixed[el.Name.Local] = el.Fixed
+	}
+	for _, attr := range order.Attributes {
+		fixed[attr.Name.Local] = attr.Fixed
+	}
+	want := map[string]string{
+		"version":  "1.0",
+		"currency": "",
+		"unit":     "kg",
+		"schema":   "orders",
+		"priority": "",
+	}
+	for name, value := range want {
+		if got, ok := fixed[name]; !ok {
+			t.Errorf("member %s not found", name)
+		} else if got != value {
+			t.Errorf("member %s has fixed value %q, want %q", name, got, value)
+		}
+	}
+}
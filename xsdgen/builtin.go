@@ -13,6 +13,30 @@ func builtinExpr(b xsd.Builtin) ast.Expr {
 	return builtinTbl[b]
 }
 
+// Returns the Go type used for b with the ArbitraryPrecision option,
+// or nil if b is mapped to the same type as usual.
+func bigNumberExpr(b xsd.Builtin) ast.Expr {
+	switch b {
+	case xsd.Decimal:
+		return &ast.Ident{Name: "XSDDecimal"}
+	case xsd.Integer, xsd.NegativeInteger, xsd.NonNegativeInteger,
+		xsd.NonPositiveInteger, xsd.PositiveInteger:
+		return &ast.StarExpr{X: &ast.Ident{Name: "big.Int"}}
+	}
+	return nil
+}
+
+// Returns true if b is one of the date and time types that are
+// mapped to time.Time.
+func isTimeBuiltin(b xsd.Builtin) bool {
+	switch b {
+	case xsd.Date, xsd.Time, xsd.DateTime,
+		xsd.GDay, xsd.GMonth, xsd.GMonthDay, xsd.GYear, xsd.GYearMonth:
+		return true
+	}
+	return false
+}
+
 // Returns true if t is an xsd.Builtin that is not trivially mapped to a
 // builtin Go type; it requires additional marshal/unmarshal methods.
 func nonTrivialBuiltin(t xsd.Type) bool {
@@ -21,7 +45,7 @@ func nonTrivialBuiltin(t xsd.Type) bool {
 		return false
 	}
 	switch b {
-	case xsd.Base64Binary, xsd.HexBinary,
+	case xsd.Base64Binary, xsd.HexBinary, xsd.Duration,
 		xsd.Date, xsd.Time, xsd.DateTime,
 		xsd.GDay, xsd.GMonth, xsd.GMonthDay, xsd.GYear, xsd.GYearMonth:
 		return true
@@ -52,9 +76,9 @@ var builtinTbl = []ast.Expr{
 	xsd.DateTime:      &ast.Ident{Name: "time.Time"},
 	xsd.Decimal:       &ast.Ident{Name: "float64"},
 	xsd.Double:        &ast.Ident{Name: "float64"},
-	// the "duration" built-in is especially broken, so we
-	// don't parse it at all.
-	xsd.Duration:           &ast.Ident{Name: "string"},
+	// xs:duration does not map to time.Duration, so a
+	// struct type is generated for it.
+	xsd.Duration:           &ast.Ident{Name: "XSDDuration"},
 	xsd.Float:              &ast.Ident{Name: "float32"},
 	xsd.GDay:               &ast.Ident{Name: "time.Time"},
 	xsd.GMonth:             &ast.Ident{Name: "time.Time"},
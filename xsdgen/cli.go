@@ -1,11 +1,15 @@
 package xsdgen
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"aqwari.net/xml/internal/commandline"
@@ -54,7 +58,10 @@ func (cfg *Config) GenCode(data ...[]byte) (*Code, error) {
 // GenAST creates an *ast.File containing type declarations and
 // associated methods based on a set of XML schema.
 func (cfg *Config) GenAST(files ...string) (*ast.File, error) {
-	data, err := cfg.readFiles(files...)
+	data, err := cfg.ReadFiles(files...)
+	if err != nil {
+		return nil, err
+	}
 	code, err := cfg.GenCode(data...)
 	if err != nil {
 		return nil, err
@@ -62,32 +69,33 @@ func (cfg *Config) GenAST(files ...string) (*ast.File, error) {
 	return code.GenAST()
 }
 
-func (cfg *Config) readFiles(files ...string) ([][]byte,error) {
+// ReadFiles reads the named schema documents with the Config's
+// SchemaResolver. If the FollowImports option is set, the documents
+// that they import or include are read as well, and follow the named
+// documents in the returned slice.
+func (cfg *Config) ReadFiles(files ...string) ([][]byte, error) {
+	r := cfg.resolver
+	if r == nil {
+		r = xsd.DefaultResolver
+	}
+	if cfg.catalog != nil {
+		r = cfg.catalog.Resolver(r)
+	}
+	if cfg.followImports {
+		data, err := xsd.ReadAll(r, files...)
+		if err != nil {
+			return nil, err
+		}
+		cfg.debugf("read %d documents from %s", len(data), strings.Join(files, ", "))
+		return data, nil
+	}
 	data := make([][]byte, 0, len(files))
 	for _, filename := range files {
-		b, err := ioutil.ReadFile(filename)
+		b, err := r.Resolve(xsd.Ref{Location: filename})
 		if err != nil {
 			return nil, err
 		}
 		cfg.debugf("read %s", filename)
-		if cfg.followImports {
-			importedRefs, err := xsd.Imports(b)
-			if err != nil {
-				return nil, fmt.Errorf("error discovering imports: %v", err)
-			}
-			importedFiles := make([]string, 0, len(importedRefs))
-			for _, r := range importedRefs {
-				importedFiles = append(importedFiles, r.Location)
-			}
-			referencedData, err := cfg.readFiles(importedFiles...)
-			if err != nil {
-				return nil, fmt.Errorf("error reading imported files: %v", err)
-			}
-			for _, d := range referencedData {
-				// prepend imported refs (i.e. append before the referencing file)
-				data = append(data, d)
-			}
-		}
 		data = append(data, b)
 	}
 	return data, nil
@@ -103,6 +111,21 @@ func (cfg *Config) GenSource(files ...string) ([]byte, error) {
 	return gen.FormattedSource(file, "fixme.go")
 }
 
+// GenPackages generates the source of a Go package for the types of
+// each namespace in the PackagePaths option, keyed by import path,
+// from a set of XML schema. See the GenPackages method of Code.
+func (cfg *Config) GenPackages(files ...string) (map[string][]byte, error) {
+	data, err := cfg.ReadFiles(files...)
+	if err != nil {
+		return nil, err
+	}
+	code, err := cfg.GenCode(data...)
+	if err != nil {
+		return nil, err
+	}
+	return code.GenPackages()
+}
+
 // GenCLI creates a file containing Go source generated from an XML
 // Schema. Main is meant to be called as part of a command, and can
 // be used to change the behavior of the xsdgen command in ways that
@@ -113,29 +136,108 @@ func (cfg *Config) GenCLI(arguments ...string) error {
 		err           error
 		replaceRules  commandline.ReplaceRuleList
 		xmlns         commandline.Strings
+		catalogs      commandline.Strings
+		packagePaths  = make(commandline.Map)
+		imports       = make(commandline.Map)
+		typeMap       = make(commandline.Map)
 		fs            = flag.NewFlagSet("xsdgen", flag.ExitOnError)
 		packageName   = fs.String("pkg", "", "name of the the generated package")
 		output        = fs.String("o", "xsdgen_output.go", "name of the output file")
+		outputDir     = fs.String("d", ".", "directory to write packages to, with -pkgmap")
+		configFile    = fs.String("config", "", "read options from a JSON file; flags take precedence")
 		followImports = fs.Bool("f", false, "follow import statements; load imported references recursively into scope")
+		choices       = fs.Bool("choice", false, "generate a type enforcing a single alternative for each choice group")
+		strictEnums   = fs.Bool("strictenums", false, "reject values outside of an enumeration when unmarshalling")
+		validate      = fs.Bool("validate", false, "generate Validate methods checking facets and required fields")
+		bigNumbers    = fs.Bool("bignum", false, "use arbitrary-precision types for xs:decimal and xs:integer")
+		preserveTime  = fs.Bool("preservetime", false, "keep the timezone and lexical form of date and time values")
+		groupStructs  = fs.Bool("groups", false, "declare a struct for each element and attribute group, embedded where it is used")
+		substitutions = fs.Bool("subst", false, "declare an interface for each substitution group, implemented by its members")
+		derivedTypes  = fs.Bool("derived", false, "declare an interface for each type with derived types, chosen by xsi:type")
+		nillable      = fs.Bool("nillable", false, "declare nillable elements with a wrapper type that records xsi:nil")
+		pointers      = fs.Bool("pointers", false, "declare optional elements and attributes of simple types as pointers")
+		roots         = fs.Bool("roots", false, "declare a wrapper type for each global element, with functions to parse and write documents")
+		manifest      = fs.Bool("manifest", false, "write a manifest of the declared types, "+manifestFile+", next to the generated code")
 		verbose       = fs.Bool("v", false, "print verbose output")
 		debug         = fs.Bool("vv", false, "print debug output")
 	)
 	fs.Var(&replaceRules, "r", "replacement rule 'regex -> repl' (can be used multiple times)")
 	fs.Var(&xmlns, "ns", "target namespace(s) to generate types for")
+	fs.Var(&catalogs, "catalog", "XML catalog mapping schema to local files (can be used multiple times)")
+	fs.Var(imports, "import", "use the types of a namespace from an existing package, as 'xmlns=importpath' (can be used multiple times)")
+	fs.Var(typeMap, "map", "declare values of an XML type with an existing Go type, as '{xmlns}name=importpath.Type' (can be used multiple times)")
+	fs.Var(packagePaths, "pkgmap", "generate a package for a namespace, as 'xmlns=importpath' (can be used multiple times)")
 
 	if err = fs.Parse(arguments); err != nil {
 		return err
 	}
 	if fs.NArg() == 0 {
-		return errors.New("Usage: xsdgen [-ns xmlns] [-r rule] [-o file] [-pkg pkg] file ...")
+		return errors.New("Usage: xsdgen [-config file] [-f] [-catalog file] [-ns xmlns] [-r rule] [-o file] [-pkg pkg] [-pkgmap xmlns=path] [-d dir] [-import xmlns=path] [-manifest] [-map {xmlns}name=path.Type] [-choice] [-strictenums] [-validate] [-bignum] [-preservetime] [-groups] [-subst] [-derived] [-nillable] [-pointers] [-roots] file ...")
+	}
+	if *configFile != "" {
+		file, err := ReadConfigFile(*configFile)
+		if err != nil {
+			return err
+		}
+		opts, err := file.Options()
+		if err != nil {
+			return fmt.Errorf("%s: %v", *configFile, err)
+		}
+		cfg.Option(opts...)
+		// Flags are added to the maps and lists of the file.
+		catalogs = append(file.Catalogs, catalogs...)
+		for ns, importPath := range file.PackagePaths {
+			if _, ok := packagePaths[ns]; !ok {
+				packagePaths[ns] = importPath
+			}
+		}
+		for ns, importPath := range file.ImportPackages {
+			if _, ok := imports[ns]; !ok {
+				imports[ns] = importPath
+			}
+		}
 	}
 	if *debug {
 		cfg.Option(LogLevel(5))
 	} else if *verbose {
 		cfg.Option(LogLevel(1))
 	}
-	cfg.Option(Namespaces(xmlns...))
-	cfg.Option(FollowImports(*followImports))
+	if len(xmlns) > 0 {
+		cfg.Option(Namespaces(xmlns...))
+	}
+	if len(catalogs) > 0 {
+		c, err := xsd.ReadCatalog(catalogs...)
+		if err != nil {
+			return err
+		}
+		cfg.Option(Catalog(c))
+	}
+	// A boolean flag overrides the file only if it is given, so
+	// that -choice=false can disable an option the file enables.
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, v := range []struct {
+		flag  string
+		value *bool
+		opt   func(bool) Option
+	}{
+		{"f", followImports, FollowImports},
+		{"choice", choices, TypeSafeChoices},
+		{"strictenums", strictEnums, StrictEnums},
+		{"validate", validate, ValidateMethods},
+		{"bignum", bigNumbers, ArbitraryPrecision},
+		{"preservetime", preserveTime, PreserveTimeFormat},
+		{"groups", groupStructs, GroupStructs},
+		{"subst", substitutions, SubstitutionGroups},
+		{"derived", derivedTypes, DerivedTypes},
+		{"nillable", nillable, NillableElements},
+		{"pointers", pointers, OptionalPointers},
+		{"roots", roots, RootElements},
+	} {
+		if set[v.flag] {
+			cfg.Option(v.opt(*v.value))
+		}
+	}
 	for _, r := range replaceRules {
 		cfg.Option(replaceAllNamesRegex(r.From, r.To))
 	}
@@ -143,14 +245,154 @@ func (cfg *Config) GenCLI(arguments ...string) error {
 		cfg.Option(PackageName(*packageName))
 	}
 
-	file, err := cfg.GenAST(fs.Args()...)
+	for _, ns := range imports.Keys() {
+		types, err := cfg.readManifest(ns, imports[ns], filepath.Dir(*output))
+		if err != nil {
+			return err
+		}
+		cfg.Option(ImportPackage(ns, imports[ns], types))
+	}
+	for _, key := range typeMap.Keys() {
+		importPath, goType := splitGoType(typeMap[key])
+		cfg.Option(MapType(parseName(key, xsd.XMLName(xsd.String).Space), importPath, goType))
+	}
+	if len(packagePaths) > 0 {
+		cfg.Option(PackagePaths(packagePaths))
+	}
+
+	data, err := cfg.ReadFiles(fs.Args()...)
 	if err != nil {
 		return err
 	}
+	code, err := cfg.GenCode(data...)
+	if err != nil {
+		return err
+	}
+
+	if len(packagePaths) > 0 {
+		packages, err := code.GenPackages()
+		if err != nil {
+			return err
+		}
+		dirs := packageDirs(packages, *outputDir)
+		for importPath, src := range packages {
+			if err := os.MkdirAll(dirs[importPath], 0777); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(filepath.Join(dirs[importPath], filepath.Base(*output)), src, 0666); err != nil {
+				return err
+			}
+			if !*manifest {
+				continue
+			}
+			m := code.Manifest()
+			for ns := range m {
+				if packagePaths[ns] != importPath {
+					delete(m, ns)
+				}
+			}
+			if err := writeManifest(filepath.Join(dirs[importPath], manifestFile), m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	file, err := code.GenAST()
+	if err != nil {
+		return err
+	}
+
+	src, err := gen.FormattedSource(file, *output)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*output, src, 0666); err != nil {
+		return err
+	}
+	if *manifest {
+		return writeManifest(filepath.Join(filepath.Dir(*output), manifestFile), code.Manifest())
+	}
+	return nil
+}
+
+// The directory of each package is named by its import path, without
+// the directories shared by the import paths of all packages, below
+// dir.
+func packageDirs(packages map[string][]byte, dir string) map[string]string {
+	var prefix []string
+	first := true
+	for importPath := range packages {
+		elems := strings.Split(importPath, "/")
+		elems = elems[:len(elems)-1]
+		if first {
+			prefix, first = elems, false
+		}
+		n := 0
+		for n < len(prefix) && n < len(elems) && prefix[n] == elems[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	dirs := make(map[string]string)
+	for importPath := range packages {
+		elems := strings.Split(importPath, "/")[len(prefix):]
+		dirs[importPath] = filepath.Join(dir, filepath.Join(elems...))
+	}
+	return dirs
+}
+
+// Splits a Go type, such as *cloud.google.com/go/civil.DateTime, into
+// the import path of its package and the type as it is referred to in
+// the generated code, *civil.DateTime. Types without an import path
+// are declared in the same package.
+func splitGoType(s string) (importPath, goType string) {
+	name := strings.TrimLeft(s, "*")
+	ptr := s[:len(s)-len(name)]
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", s
+	}
+	importPath = name[:slash+1+dot]
+	return importPath, ptr + packageName(importPath) + name[slash+1+dot:]
+}
+
+// The manifest of the types declared by the xsdgen command is written
+// to this file, in the directory of the generated code.
+const manifestFile = "xsdgen_manifest.json"
 
-	data, err := gen.FormattedSource(file, *output)
+func writeManifest(filename string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "\t")
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(*output, data, 0666)
+	return ioutil.WriteFile(filename, append(data, '\n'), 0666)
+}
+
+// Reads the names of the types of a namespace from the manifest of
+// an imported package. If the package or its manifest cannot be
+// found, the names are not checked.
+func (cfg *Config) readManifest(ns, importPath, srcDir string) (map[string]string, error) {
+	pkg, err := build.Import(importPath, srcDir, build.FindOnly)
+	if err != nil {
+		cfg.logf("cannot find package %s, type names are not checked: %v", importPath, err)
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(pkg.Dir, manifestFile))
+	if os.IsNotExist(err) {
+		cfg.logf("package %s has no %s, type names are not checked", importPath, manifestFile)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Join(pkg.Dir, manifestFile), err)
+	}
+	types, ok := m[ns]
+	if !ok {
+		return nil, fmt.Errorf("package %s does not declare the types of namespace %s", importPath, ns)
+	}
+	return types, nil
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"go/ast"
 	"go/format"
+	"go/parser"
 	"regexp"
 	"strings"
 
@@ -16,12 +17,16 @@ import (
 // A Config holds user-defined overrides and filters that are used when
 // generating Go source code from an xsd document.
 type Config struct {
-	logger          Logger
-	loglevel        int
-	namespaces      []string
-	pkgname         string
+	logger     Logger
+	loglevel   int
+	namespaces []string
+	pkgname    string
 	// load xsd imports recursively into memory before parsing
-	followImports   bool
+	followImports bool
+	// retrieves schema documents; xsd.DefaultResolver if nil
+	resolver xsd.SchemaResolver
+	// maps schema locations and namespaces to local copies
+	catalog         *xsd.Catalog
 	preprocessType  typeTransform
 	postprocessType specTransform
 	// Helper functions
@@ -42,6 +47,39 @@ type Config struct {
 	// if populated, only types that are true in this map
 	// will be selected.
 	allowTypes map[xml.Name]bool
+
+	// generate a separate type for <choice> groups
+	typeSafeChoices bool
+	// reject values outside of an enumeration when unmarshalling
+	strictEnums bool
+	// generate Validate methods for all types
+	validateMethods bool
+	// use arbitrary-precision types for decimals and integers
+	arbitraryPrecision bool
+	// keep the timezone and lexical form of date and time values
+	preserveTimeFormat bool
+	// declare a struct for each group, embedded where it is used
+	groupStructs bool
+	groups       map[groupKey]*groupStruct
+	// declare an interface for each substitution group
+	substitutionGroups bool
+	substitutions      map[xml.Name]*substitutionGroup
+	// declare an interface for each type with derived types
+	derivedTypes bool
+	derived      map[xml.Name]*derivedTypes
+	// declare nillable elements with a wrapper that records xsi:nil
+	nillableElements bool
+	nillables        map[xml.Name]*nillableType
+	// declare optional simple-typed fields as pointers
+	optionalPointers bool
+	// declare a wrapper type for each global element
+	rootElements bool
+	// import paths of the packages declaring each namespace's types
+	packagePaths map[string]string
+	// namespaces whose types are declared in existing packages
+	imports map[string]importedPackage
+	// XML types held by existing Go types
+	typeMap map[xml.Name]mappedType
 }
 
 type typeTransform func(xsd.Schema, xsd.Type) xsd.Type
@@ -247,6 +285,308 @@ func FollowImports(follow bool) Option {
 	}
 }
 
+// The Resolver Option sets the xsd.SchemaResolver used to read schema
+// documents, including those imported or included by other documents
+// when the FollowImports option is set. Relative schema locations are
+// resolved against the location of the document referencing them. By
+// default, xsd.DefaultResolver is used, which reads local files and
+// fetches http and https URLs.
+func Resolver(r xsd.SchemaResolver) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.resolver
+		cfg.resolver = r
+		return Resolver(prev)
+	}
+}
+
+// The Catalog Option sets an OASIS XML Catalog, which is consulted
+// before the Config's SchemaResolver when reading schema documents.
+// With the FollowImports option, the catalog can supply local copies
+// of remote schema, and of imported namespaces that have no
+// schemaLocation.
+func Catalog(c *xsd.Catalog) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.catalog
+		cfg.catalog = c
+		return Catalog(prev)
+	}
+}
+
+// The TypeSafeChoices Option changes the Go source generated for
+// complex types containing a <choice> group. Instead of declaring
+// an optional struct field for each alternative, a single field
+// named Choice is declared, whose type holds at most one of the
+// alternatives. The type has a Which method returning the name of
+// the element it holds, along with a getter and setter for each
+// alternative. Its MarshalXML and UnmarshalXML methods return an
+// error if more than one alternative is present, or if a required
+// choice is missing.
+//
+// Only the first <choice> in a complex type is handled this way,
+// and only if its alternatives are all elements, it may occur at
+// most once, and the type does not contain an <xs:any> wildcard.
+// Other choice groups are generated as usual.
+func TypeSafeChoices(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.typeSafeChoices
+		cfg.typeSafeChoices = enable
+		return TypeSafeChoices(prev)
+	}
+}
+
+// The StrictEnums Option generates an UnmarshalText method for
+// each simple type with enumerated values, which returns an error
+// if the value being unmarshalled is not one of the enumerated
+// values. Without this option, any value of the underlying type is
+// accepted, and may be checked with the type's IsValid method.
+func StrictEnums(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.strictEnums
+		cfg.strictEnums = enable
+		return StrictEnums(prev)
+	}
+}
+
+// The ValidateMethods Option generates a Validate method for every
+// simple and complex type. The Validate method of a simple type
+// returns an error if its value does not satisfy the facets of the
+// type's restriction, such as its pattern, length, range or
+// enumerated values. The Validate method of a complex type returns
+// an error if a required element or attribute is missing, and calls
+// the Validate method of each of its fields.
+//
+// Because the zero value of a struct field cannot be distinguished
+// from a missing value, optional fields holding the zero value are
+// not validated, and required numeric and boolean fields are never
+// reported as missing. Simple types whose only restrictions are
+// numeric or length facets, which are otherwise generated as their
+// underlying builtin type, are declared as their own types when
+// this option is enabled.
+func ValidateMethods(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.validateMethods
+		cfg.validateMethods = enable
+		return ValidateMethods(prev)
+	}
+}
+
+// The ArbitraryPrecision Option changes the Go types used for
+// numbers that may not fit in a float64 or an int. Values of type
+// xs:decimal are held by the generated XSDDecimal type, which keeps
+// the decimal exactly as it appears in the document, and can be
+// converted to and from a *big.Rat. Values of the unbounded integer
+// types xs:integer, xs:nonNegativeInteger, xs:positiveInteger,
+// xs:nonPositiveInteger and xs:negativeInteger are held by a
+// *big.Int. Simple types derived from these integer types are
+// declared as *big.Int as well, without their enumerated constants.
+func ArbitraryPrecision(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.arbitraryPrecision
+		cfg.arbitraryPrecision = enable
+		return ArbitraryPrecision(prev)
+	}
+}
+
+// The PreserveTimeFormat Option changes the Go types used for the
+// date and time types xs:date, xs:dateTime, xs:time, xs:gDay,
+// xs:gMonth, xs:gMonthDay, xs:gYear and xs:gYearMonth. By default,
+// these values are held by a time.Time, and are always written with
+// a timezone, even if they were read without one. When this option
+// is enabled, each type is held by a generated struct, such as
+// XSDDateTime, which records whether the value had a timezone. A
+// value that is unmarshalled and marshalled again without being
+// modified keeps its original lexical form, including the precision
+// of its fractional seconds. Negative years are also supported.
+func PreserveTimeFormat(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.preserveTimeFormat
+		cfg.preserveTimeFormat = enable
+		return PreserveTimeFormat(prev)
+	}
+}
+
+// The GroupStructs Option declares a struct type for each named
+// <group> and <attributeGroup> that is used by a complex type, and
+// embeds it in the struct of the complex type, instead of declaring
+// the fields of the group in every type that uses it. The struct
+// types are named after the group, with a "Group" or "Attrs" suffix.
+// A group whose members are changed by the type that uses it, such
+// as an optional group or one that occurs more than once, is not
+// embedded.
+func GroupStructs(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.groupStructs
+		cfg.groupStructs = enable
+		return GroupStructs(prev)
+	}
+}
+
+// The SubstitutionGroups Option declares an interface for each
+// element that is the head of a substitution group, such as
+// ShapeElement for the element "shape". The interface is implemented
+// by the types of the head and of the elements that may appear in
+// its place, and fields that refer to the head are declared with
+// the interface type. When unmarshalling, the type of each value is
+// chosen by the name of its element; when marshalling, the element
+// is named after the type of the value. Since the field matches
+// elements of any name, a complex type may have only one such field,
+// and none if it contains an <xs:any> wildcard or a choice generated
+// by the TypeSafeChoices option. Elements declared in the struct of
+// a group by the GroupStructs option are not changed.
+func SubstitutionGroups(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.substitutionGroups
+		cfg.substitutionGroups = enable
+		return SubstitutionGroups(prev)
+	}
+}
+
+// The DerivedTypes Option declares an interface for each complex
+// type that other complex types are derived from, by extension or
+// restriction, such as AnyAnimal for the type "animal". The interface
+// is implemented by pointers to the base type and to each type
+// derived from it, and elements of the base type are declared with
+// the interface type. When unmarshalling, the type of each value is
+// chosen by the xsi:type attribute of its element, and the base type
+// is used if the attribute is missing or names an unknown type. When
+// marshalling, the xsi:type attribute is added to the elements of
+// derived types. The types that may be named by the attribute are
+// kept in a map for each base type, keyed by their qualified names.
+func DerivedTypes(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.derivedTypes
+		cfg.derivedTypes = enable
+		return DerivedTypes(prev)
+	}
+}
+
+// The NillableElements Option declares each nillable element with
+// a wrapper type, such as NillableString for elements of type
+// xs:string, that holds the element's value and whether it has the
+// attribute xsi:nil="true". Optional nillable elements are declared
+// as pointers to the wrapper, so that an absent element (a nil
+// pointer), a nil element and an empty element are distinct. A
+// wrapper with Nil set is marshalled as an empty element with
+// xsi:nil="true". Without this option, a nil element is unmarshalled
+// as the zero value of its type, and cannot be marshalled.
+func NillableElements(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.nillableElements
+		cfg.nillableElements = enable
+		return NillableElements(prev)
+	}
+}
+
+// The OptionalPointers Option declares optional elements and
+// attributes of simple types as pointers, so that a missing element
+// or attribute (a nil pointer) can be told apart from one that is
+// present with the zero value of its type, such as 0 or false.
+// Without this option, the zero value is omitted when marshalling.
+// Types declared as slices, which are nil when missing, and date and
+// time types, whose zero value is always omitted, are not affected.
+func OptionalPointers(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.optionalPointers
+		cfg.optionalPointers = enable
+		return OptionalPointers(prev)
+	}
+}
+
+// The RootElements Option declares a wrapper type for each global
+// element of the target namespaces, with an XMLName field holding the
+// element's name, so that documents whose root is the element can be
+// decoded and encoded. The wrapper embeds the type of the element if
+// it is a complex type, and holds its value in a Value field otherwise.
+// A function such as ParseInvoice decodes a document from an
+// io.Reader, and the WriteTo method of the wrapper encodes it. The
+// wrapper is named after the element, with a "Document" suffix if
+// the name is taken, and the element is skipped if that name is
+// taken as well.
+func RootElements(enable bool) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.rootElements
+		cfg.rootElements = enable
+		return RootElements(prev)
+	}
+}
+
+// The PackagePaths Option maps target namespaces to the import paths
+// of Go packages, for use with the GenPackages method of Code. The
+// types of each namespace are declared in its package, and refer to
+// the types of other namespaces through their packages, so types that
+// are shared by several namespaces are declared once. Namespaces can
+// share a package, and must do so if their types refer to each other.
+// Each package is named after the last element of its import path.
+func PackagePaths(paths map[string]string) Option {
+	return func(cfg *Config) Option {
+		prev := cfg.packagePaths
+		cfg.packagePaths = paths
+		return PackagePaths(prev)
+	}
+}
+
+// The ImportPackage Option declares that the types of a namespace
+// are declared in the Go package with the given import path, such as
+// a package generated earlier for a schema that several schema import.
+// The types are referred to through the package, as in common.Address,
+// instead of being declared again. If types is not nil, it maps the
+// names of the namespace's XML types to the names of their Go types,
+// as in the package's Manifest, and it is an error to refer to a type
+// that is not in it. Otherwise, the Go types are assumed to be named
+// as they would be by the current Config.
+func ImportPackage(ns, importPath string, types map[string]string) Option {
+	return func(cfg *Config) Option {
+		if cfg.imports == nil {
+			cfg.imports = make(map[string]importedPackage)
+		}
+		prev, ok := cfg.imports[ns]
+		cfg.imports[ns] = importedPackage{
+			path:  importPath,
+			name:  packageName(importPath),
+			types: types,
+		}
+		if ok {
+			return ImportPackage(ns, prev.path, prev.types)
+		}
+		return func(cfg *Config) Option {
+			delete(cfg.imports, ns)
+			return ImportPackage(ns, importPath, types)
+		}
+	}
+}
+
+// The MapType Option declares values of the XML type name with an
+// existing Go type, such as "civil.DateTime" or "*big.Float", from the
+// package with the given import path, which is imported by the generated
+// code. The import path may be empty for types declared in the same
+// package. The Go type is not declared, and must unmarshal and marshal
+// itself, for instance by implementing encoding.TextUnmarshaler and
+// encoding.TextMarshaler. Built-in types, such as xs:dateTime in the
+// XML Schema namespace, can be mapped as well as the types of a schema.
+// Simple types derived from a mapped type are declared with the Go type
+// it is mapped to.
+func MapType(name xml.Name, importPath, goType string) Option {
+	m, err := newMappedType(importPath, goType)
+	return func(cfg *Config) Option {
+		if err != nil {
+			cfg.logf("invalid Go type %q passed to MapType: %v", goType, err)
+			return func(*Config) Option { return MapType(name, importPath, goType) }
+		}
+		if cfg.typeMap == nil {
+			cfg.typeMap = make(map[xml.Name]mappedType)
+		}
+		prev, ok := cfg.typeMap[name]
+		cfg.typeMap[name] = m
+		if ok {
+			return MapType(name, prev.path, prev.goType)
+		}
+		return func(cfg *Config) Option {
+			delete(cfg.typeMap, name)
+			return MapType(name, importPath, goType)
+		}
+	}
+}
+
 // Replace allows for substitution rules for all identifiers to
 // be specified. If an invalid regular expression is called, no action
 // is taken. The Replace option is additive; subsitutions will be
@@ -440,17 +780,37 @@ func (cfg *Config) filterFields(t *xsd.ComplexType) ([]xsd.Attribute, []xsd.Elem
 // Return the identifier for non-builtin types, or the Go expression
 // mapped to the built-in type.
 func (cfg *Config) expr(t xsd.Type) (ast.Expr, error) {
+	if m, ok := cfg.mappedType(t); ok {
+		return parser.ParseExpr(m.goType)
+	}
 	if t, ok := t.(xsd.Builtin); ok {
+		if cfg.arbitraryPrecision {
+			if ex := bigNumberExpr(t); ex != nil {
+				return ex, nil
+			}
+		}
+		if cfg.preserveTimeFormat && isTimeBuiltin(t) {
+			return ast.NewIdent("XSD" + t.String()), nil
+		}
 		ex := builtinExpr(t)
 		if ex == nil {
 			return nil, fmt.Errorf("Unknown built-in type %q", t.Name().Local)
 		}
 		return ex, nil
 	}
+	if imp, ok := cfg.imports[xsd.XMLName(t).Space]; ok {
+		name, _ := imp.typeName(cfg, t)
+		return ast.NewIdent(name), nil
+	}
 	return ast.NewIdent(cfg.public(xsd.XMLName(t))), nil
 }
 
 func (cfg *Config) exprString(t xsd.Type) string {
+	if m, ok := cfg.mappedType(t); ok {
+		// The parsed expression has positions that format.Node
+		// cannot look up.
+		return m.goType
+	}
 	var buf bytes.Buffer
 	expr, err := cfg.expr(t)
 	if err != nil {
@@ -605,12 +965,127 @@ func (cfg *Config) addStandardHelpers() {
 				}
 				return err
 			`),
+		gen.Func("_unmarshalText").
+			Args("text []byte", "v interface{}").
+			Returns("error").
+			Body(`
+				var buf bytes.Buffer
+				buf.WriteString("<v>")
+				xml.EscapeText(&buf, text)
+				buf.WriteString("</v>")
+				return xml.Unmarshal(buf.Bytes(), v)
+			`),
+		gen.Func("_countDigits").
+			Args("s string").
+			Returns("intDigits int", "fracDigits int").
+			Body(`
+				s = strings.TrimLeft(s, "+-")
+				parts := strings.SplitN(s, ".", 2)
+				intDigits = len(strings.TrimLeft(parts[0], "0"))
+				if len(parts) == 2 {
+					fracDigits = len(strings.TrimRight(parts[1], "0"))
+				}
+				if intDigits == 0 && fracDigits == 0 {
+					intDigits = 1
+				}
+				return intDigits, fracDigits
+			`),
 		gen.Func("_marshalTime").
 			Args("t time.Time", "format string").
 			Returns("[]byte", "error").
 			Body(`
 				return []byte(t.Format(format + "Z07:00")), nil
 			`),
+		gen.Func("_parseTime").
+			Args("text string", "format string").
+			Returns("t time.Time", "hasTimezone bool", "err error").
+			Body(`
+				var negative bool
+				if strings.HasPrefix(format, "2006") && strings.HasPrefix(text, "-") {
+					negative, text = true, text[1:]
+				}
+				t, err = time.Parse(format, text)
+				if _, ok := err.(*time.ParseError); ok {
+					t, err = time.Parse(format + "Z07:00", text)
+					hasTimezone = err == nil
+				}
+				if err == nil && negative {
+					t = time.Date(-t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(),
+						t.Second(), t.Nanosecond(), t.Location())
+				}
+				return t, hasTimezone, err
+			`),
+		gen.Func("_formatTime").
+			Args("t time.Time", "hasTimezone bool", "text", "format string").
+			Returns("[]byte").
+			Body(`
+				if text != "" {
+					orig, tz, err := _parseTime(text, format)
+					_, offset := orig.Zone()
+					_, want := t.Zone()
+					if err == nil && tz == hasTimezone && orig.Equal(t) && offset == want {
+						return []byte(text)
+					}
+				}
+				if hasTimezone {
+					format += "Z07:00"
+				}
+				return []byte(t.Format(format))
+			`),
+		gen.Func("_xsiType").
+			Args("start xml.StartElement").
+			Returns("name xml.Name", "ok bool").
+			Body(`
+				for _, attr := range start.Attr {
+					if attr.Name.Space != "http://www.w3.org/2001/XMLSchema-instance" || attr.Name.Local != "type" {
+						continue
+					}
+					prefix, local := "", strings.TrimSpace(attr.Value)
+					if i := strings.Index(local, ":"); i >= 0 {
+						prefix, local = local[:i], local[i+1:]
+					}
+					name.Local = local
+					// The namespace of a prefix declared by an ancestor of
+					// the element is not known, and is left empty; callers
+					// must then match the type by its local name only.
+					for _, a := range start.Attr {
+						if prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns" ||
+							prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix {
+							name.Space = a.Value
+						}
+					}
+					return name, true
+				}
+				return name, false
+			`),
+		gen.Func("_xsiNil").
+			Args("start xml.StartElement").
+			Returns("bool").
+			Body(`
+				for _, attr := range start.Attr {
+					if attr.Name.Space == "http://www.w3.org/2001/XMLSchema-instance" && attr.Name.Local == "nil" {
+						v := strings.TrimSpace(attr.Value)
+						return v == "true" || v == "1"
+					}
+				}
+				return false
+			`),
+		gen.Func("_xsiTypeAttr").
+			Args("name xml.Name").
+			Returns("[]xml.Attr").
+			Body(`
+				// The encoder writes attribute names without a namespace
+				// as they are, so that the prefixes can be declared.
+				attr := []xml.Attr{
+					{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"},
+					{Name: xml.Name{Local: "xsi:type"}, Value: name.Local},
+				}
+				if name.Space != "" {
+					attr[1].Value = "xsitype:" + name.Local
+					attr = append(attr, xml.Attr{Name: xml.Name{Local: "xmlns:xsitype"}, Value: name.Space})
+				}
+				return attr
+			`),
 	}
 	for _, fn := range fns {
 		cfg.helperFuncs[fn.Name()] = fn.MustDecl()
@@ -629,6 +1104,10 @@ func (cfg *Config) addStandardHelpers() {
 	}
 
 	for timeType, timeSpec := range timeTypes {
+		if cfg.preserveTimeFormat {
+			cfg.helperTypes[xsd.XMLName(timeType)] = timeFormatSpec(timeType, timeSpec)
+			continue
+		}
 		name := "xsd" + timeType.String()
 		cfg.helperTypes[xsd.XMLName(timeType)] = spec{
 			name:    name,
@@ -678,6 +1157,236 @@ func (cfg *Config) addStandardHelpers() {
 		}
 	}
 
+	cfg.helperTypes[xsd.XMLName(xsd.Duration)] = spec{
+		name: "XSDDuration",
+		doc: "An XSDDuration is a length of time, as represented by the xs:duration\n" +
+			"type. Years and months are kept separate from the other components,\n" +
+			"because their length varies. All components are non-negative; the\n" +
+			"sign of the duration is held by the Negative field. A decoded\n" +
+			"duration is encoded in the form it was read in, such as P1Y0M,\n" +
+			"until it is changed. The zero XSDDuration is encoded as PT0S.",
+		expr: gen.Struct(
+			ast.NewIdent("Negative"), ast.NewIdent("bool"), nil,
+			ast.NewIdent("Years"), ast.NewIdent("int"), nil,
+			ast.NewIdent("Months"), ast.NewIdent("int"), nil,
+			ast.NewIdent("Days"), ast.NewIdent("int"), nil,
+			ast.NewIdent("Hours"), ast.NewIdent("int"), nil,
+			ast.NewIdent("Minutes"), ast.NewIdent("int"), nil,
+			ast.NewIdent("Seconds"), ast.NewIdent("int"), nil,
+			ast.NewIdent("Nanoseconds"), ast.NewIdent("int"), nil,
+			ast.NewIdent("lexical"), ast.NewIdent("string"), nil),
+		xsdType: xsd.Duration,
+		methods: []*ast.FuncDecl{
+			gen.Func("UnmarshalText").
+				Receiver("d *XSDDuration").
+				Args("text []byte").
+				Returns("error").
+				Body(`
+					var v XSDDuration
+					s := string(bytes.TrimSpace(text))
+					if strings.HasPrefix(s, "-") {
+						v.Negative, s = true, s[1:]
+					}
+					if len(s) < 2 || s[0] != 'P' || strings.HasSuffix(s, "T") {
+						return fmt.Errorf("invalid duration %%q", text)
+					}
+					// units that may still follow, in order
+					units, isTime := "YMD", false
+					for s = s[1:]; s != ""; {
+						if s[0] == 'T' && !isTime {
+							units, isTime, s = "HMS", true, s[1:]
+							continue
+						}
+						i := strings.IndexFunc(s, func(r rune) bool {
+							return (r < '0' || r > '9') && r != '.'
+						})
+						if i <= 0 {
+							return fmt.Errorf("invalid duration %%q", text)
+						}
+						num, unit := s[:i], s[i]
+						s = s[i+1:]
+						if j := strings.IndexByte(units, unit); j < 0 {
+							return fmt.Errorf("invalid duration %%q", text)
+						} else {
+							units = units[j+1:]
+						}
+						frac := ""
+						if unit == 'S' {
+							if i := strings.IndexByte(num, '.'); i >= 0 {
+								num, frac = num[:i], num[i+1:]
+								if frac == "" || len(frac) > 9 && strings.Trim(frac[9:], "0") != "" {
+									return fmt.Errorf("invalid duration %%q", text)
+								}
+							}
+						}
+						n, err := strconv.Atoi(num)
+						if err != nil {
+							return fmt.Errorf("invalid duration %%q", text)
+						}
+						switch {
+						case unit == 'Y':
+							v.Years = n
+						case unit == 'M' && !isTime:
+							v.Months = n
+						case unit == 'D':
+							v.Days = n
+						case unit == 'H':
+							v.Hours = n
+						case unit == 'M':
+							v.Minutes = n
+						case unit == 'S':
+							v.Seconds = n
+							if frac != "" {
+								frac = (frac + "000000000")[:9]
+								if v.Nanoseconds, err = strconv.Atoi(frac); err != nil {
+									return fmt.Errorf("invalid duration %%q", text)
+								}
+							}
+						}
+					}
+					v.lexical = string(text)
+					*d = v
+					return nil
+				`).MustDecl(),
+			gen.Func("MarshalText").
+				Receiver("d XSDDuration").
+				Returns("[]byte", "error").
+				Body(`
+					if d.lexical != "" {
+						var v XSDDuration
+						if v.UnmarshalText([]byte(d.lexical)) == nil && v == d {
+							return []byte(d.lexical), nil
+						}
+					}
+					var buf bytes.Buffer
+					if d.Negative {
+						buf.WriteByte('-')
+					}
+					buf.WriteByte('P')
+					if d.Years != 0 {
+						fmt.Fprintf(&buf, "%%dY", d.Years)
+					}
+					if d.Months != 0 {
+						fmt.Fprintf(&buf, "%%dM", d.Months)
+					}
+					if d.Days != 0 {
+						fmt.Fprintf(&buf, "%%dD", d.Days)
+					}
+					if d.Hours != 0 || d.Minutes != 0 || d.Seconds != 0 || d.Nanoseconds != 0 {
+						buf.WriteByte('T')
+						if d.Hours != 0 {
+							fmt.Fprintf(&buf, "%%dH", d.Hours)
+						}
+						if d.Minutes != 0 {
+							fmt.Fprintf(&buf, "%%dM", d.Minutes)
+						}
+						if d.Seconds != 0 || d.Nanoseconds != 0 {
+							buf.WriteString(strconv.Itoa(d.Seconds))
+							if d.Nanoseconds != 0 {
+								frac := fmt.Sprintf("%%09d", d.Nanoseconds)
+								buf.WriteString("." + strings.TrimRight(frac, "0"))
+							}
+							buf.WriteByte('S')
+						}
+					}
+					if buf.Bytes()[buf.Len()-1] == 'P' {
+						buf.WriteString("T0S")
+					}
+					return buf.Bytes(), nil
+				`).MustDecl(),
+			gen.Func("Duration").
+				Receiver("d XSDDuration").
+				Returns("time.Duration", "bool").
+				Comment("Duration converts d to a time.Duration, counting a day as 24 hours.\n" +
+					"It returns false if d has a year or month component, or if d\n" +
+					"is too long to be represented as a time.Duration.").
+				Body(`
+					if d.Years != 0 || d.Months != 0 {
+						return 0, false
+					}
+					secs := float64(d.Days)*86400 + float64(d.Hours)*3600 +
+						float64(d.Minutes)*60 + float64(d.Seconds)
+					if secs >= float64(math.MaxInt64/int64(time.Second)) {
+						return 0, false
+					}
+					v := time.Duration(d.Days)*24*time.Hour + time.Duration(d.Hours)*time.Hour +
+						time.Duration(d.Minutes)*time.Minute + time.Duration(d.Seconds)*time.Second +
+						time.Duration(d.Nanoseconds)
+					if d.Negative {
+						v = -v
+					}
+					return v, true
+				`).MustDecl(),
+		},
+	}
+
+	cfg.helperTypes[xsd.XMLName(xsd.Decimal)] = spec{
+		name: "XSDDecimal",
+		doc: "An XSDDecimal is an xs:decimal value. It holds the decimal exactly\n" +
+			"as it appears in the document, so that no precision is lost.",
+		expr:    ast.NewIdent("string"),
+		xsdType: xsd.Decimal,
+		methods: []*ast.FuncDecl{
+			gen.Func("UnmarshalText").
+				Receiver("d *XSDDecimal").
+				Args("text []byte").
+				Returns("error").
+				Body(`
+					s := string(bytes.TrimSpace(text))
+					digits := strings.TrimLeft(s, "+-")
+					if len(s)-len(digits) > 1 || strings.Count(digits, ".") > 1 ||
+						strings.Trim(digits, ".") == "" ||
+						strings.Trim(digits, ".0123456789") != "" {
+						return fmt.Errorf("invalid decimal %%q", text)
+					}
+					*d = XSDDecimal(s)
+					return nil
+				`).MustDecl(),
+			gen.Func("MarshalText").
+				Receiver("d XSDDecimal").
+				Returns("[]byte", "error").
+				Body(`return []byte(d), nil`).
+				MustDecl(),
+			gen.Func("Rat").
+				Receiver("d XSDDecimal").
+				Returns("*big.Rat", "bool").
+				Comment("Rat returns the value of d as a big.Rat. It returns false if d\n" +
+					"is not a valid decimal.").
+				Body(`return new(big.Rat).SetString(string(d))`).
+				MustDecl(),
+			gen.Func("SetRat").
+				Receiver("d *XSDDecimal").
+				Args("r *big.Rat").
+				Returns("bool").
+				Comment("SetRat sets d to the value of r. It returns false, leaving d\n" +
+					"unchanged, if r has no exact decimal representation.").
+				Body(`
+					// r has a finite decimal expansion if the only prime
+					// factors of its denominator are 2 and 5.
+					den, prec := new(big.Int).Set(r.Denom()), 0
+					for _, p := range []int64{2, 5} {
+						n, q, m := 0, new(big.Int), new(big.Int)
+						for {
+							q.QuoRem(den, big.NewInt(p), m)
+							if m.Sign() != 0 {
+								break
+							}
+							den.Set(q)
+							n++
+						}
+						if n > prec {
+							prec = n
+						}
+					}
+					if den.Cmp(big.NewInt(1)) != 0 {
+						return false
+					}
+					*d = XSDDecimal(r.FloatString(prec))
+					return true
+				`).MustDecl(),
+		},
+	}
+
 	cfg.helperTypes[xsd.XMLName(xsd.HexBinary)] = spec{
 		name:    "xsd" + xsd.HexBinary.String(),
 		expr:    builtinExpr(xsd.HexBinary),
@@ -732,6 +1441,78 @@ func (cfg *Config) addStandardHelpers() {
 	}
 }
 
+// Returns the helper type used for a date or time type with the
+// PreserveTimeFormat option. The original text of a value is kept
+// in an unexported field, so that it can be written out unchanged.
+func timeFormatSpec(timeType xsd.Builtin, timeSpec string) spec {
+	name := "XSD" + timeType.String()
+	return spec{
+		name: name,
+		doc: fmt.Sprintf("An %s is an xs:%s value. HasTimezone reports whether\n"+
+			"the value has a timezone; if it does not, the location of Time\n"+
+			"is UTC, and is not written out when the value is marshalled.",
+			name, xsd.XMLName(timeType).Local),
+		expr: gen.Struct(
+			ast.NewIdent("Time"), ast.NewIdent("time.Time"), nil,
+			ast.NewIdent("HasTimezone"), ast.NewIdent("bool"), nil,
+			ast.NewIdent("text"), ast.NewIdent("string"), nil),
+		xsdType: timeType,
+		methods: []*ast.FuncDecl{
+			gen.Func("UnmarshalText").
+				Receiver("t *"+name).
+				Args("text []byte").
+				Returns("error").
+				Body(`
+					s := string(bytes.TrimSpace(text))
+					v, tz, err := _parseTime(s, %q)
+					if err != nil {
+						return err
+					}
+					*t = %s{Time: v, HasTimezone: tz, text: s}
+					return nil
+				`, timeSpec, name).
+				MustDecl(),
+			gen.Func("MarshalText").
+				Receiver("t "+name).
+				Returns("[]byte", "error").
+				Body(`return _formatTime(t.Time, t.HasTimezone, t.text, %q), nil`, timeSpec).
+				MustDecl(),
+			gen.Func("String").
+				Receiver("t "+name).
+				Returns("string").
+				Body(`return string(_formatTime(t.Time, t.HasTimezone, t.text, %q))`, timeSpec).
+				MustDecl(),
+			// workaround golang.org/issues/11939
+			gen.Func("MarshalXML").
+				Receiver("t "+name).
+				Args("e *xml.Encoder", "start xml.StartElement").
+				Returns("error").
+				Body(`
+					if t.Time.IsZero() {
+						return nil
+					}
+					m, err := t.MarshalText()
+					if err != nil {
+						return err
+					}
+					return e.EncodeElement(m, start)
+				`).MustDecl(),
+			gen.Func("MarshalXMLAttr").
+				Receiver("t "+name).
+				Args("name xml.Name").
+				Returns("xml.Attr", "error").
+				Body(`
+					if t.Time.IsZero() {
+						return xml.Attr{}, nil
+					}
+					m, err := t.MarshalText()
+					return xml.Attr{Name: name, Value: string(m)}, err
+				`).MustDecl(),
+		},
+		helperFuncs: []string{"_parseTime", "_formatTime"},
+	}
+}
+
 // SOAP arrays (and other similar types) are complex types with a single
 // plural element. We add a post-processing step to flatten it out and provide
 // marshal/unmarshal methods.
@@ -862,5 +1643,31 @@ func (cfg *Config) soapArrayToSlice(s spec) spec {
 	s.expr = slice
 	s.methods = append(s.methods, marshal)
 	s.methods = append(s.methods, unmarshal)
+	if cfg.validateMethods {
+		// The Validate method of the struct type refers to
+		// its field, which no longer exists. Other types still
+		// call the Validate method of the array, so one is
+		// always declared.
+		s.methods = removeMethod(s.methods, "Validate")
+		body := "return nil"
+		for _, el := range complex.Elements {
+			if el.Wildcard && cfg.hasValidate(el.Type) {
+				body = `
+					for i := range a {
+						if err := a[i].Validate(); err != nil {
+							return fmt.Errorf("item[%%d]: %%v", i, err)
+						}
+					}
+					return nil
+				`
+				break
+			}
+		}
+		s.methods = append(s.methods, gen.Func("Validate").
+			Receiver("a "+s.name).
+			Returns("error").
+			Comment("Validate returns an error if any item in a is invalid.").
+			Body(body).MustDecl())
+	}
 	return s
 }
@@ -0,0 +1,181 @@
+package xsdgen
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"strings"
+
+	"aqwari.net/xml/internal/commandline"
+	"aqwari.net/xml/xsd"
+)
+
+// A ConfigFile holds the options of a Config in a JSON document, so
+// that the settings used to generate a package can be kept, and
+// reviewed, in one place. Each field corresponds to the Option of the
+// same name; options that are not set in the file are left unchanged.
+// Boolean options can only be enabled. A list of names replaces the
+// names of the corresponding Option, so an empty list clears them.
+//
+// The LogOutput, Resolver and ProcessTypes options, whose arguments
+// are Go values, have no counterpart.
+type ConfigFile struct {
+	Namespaces  []string `json:"namespaces,omitempty"`
+	PackageName string   `json:"packageName,omitempty"`
+	LogLevel    int      `json:"logLevel,omitempty"`
+
+	FollowImports bool `json:"followImports,omitempty"`
+	// Files containing XML catalogs, relative to the configuration
+	// file.
+	Catalogs []string `json:"catalogs,omitempty"`
+
+	IgnoreAttributes []string `json:"ignoreAttributes,omitempty"`
+	IgnoreElements   []string `json:"ignoreElements,omitempty"`
+	OnlyTypes        []string `json:"onlyTypes,omitempty"`
+	// Names of XML types, as {xmlns}name
+	AllowTypes []string `json:"allowTypes,omitempty"`
+	// Replacement rules, as "regex -> repl", applied in order after
+	// any rules already set.
+	Replace []string `json:"replace,omitempty"`
+
+	TypeSafeChoices     bool `json:"typeSafeChoices,omitempty"`
+	StrictEnums         bool `json:"strictEnums,omitempty"`
+	ValidateMethods     bool `json:"validateMethods,omitempty"`
+	ArbitraryPrecision  bool `json:"arbitraryPrecision,omitempty"`
+	PreserveTimeFormat  bool `json:"preserveTimeFormat,omitempty"`
+	GroupStructs        bool `json:"groupStructs,omitempty"`
+	SubstitutionGroups  bool `json:"substitutionGroups,omitempty"`
+	DerivedTypes        bool `json:"derivedTypes,omitempty"`
+	NillableElements    bool `json:"nillableElements,omitempty"`
+	OptionalPointers    bool `json:"optionalPointers,omitempty"`
+	RootElements        bool `json:"rootElements,omitempty"`
+	UseFieldNames       bool `json:"useFieldNames,omitempty"`
+	HandleSOAPArrayType bool `json:"handleSOAPArrayType,omitempty"`
+	SOAPArrayAsSlice    bool `json:"soapArrayAsSlice,omitempty"`
+
+	// Import paths of the packages to generate, keyed by namespace
+	PackagePaths map[string]string `json:"packagePaths,omitempty"`
+	// Import paths of existing packages, keyed by namespace
+	ImportPackages map[string]string `json:"importPackages,omitempty"`
+	// Go types, as importpath.Type, keyed by XML type, as in the
+	// -map flag of the xsdgen command
+	MapTypes map[string]string `json:"mapTypes,omitempty"`
+}
+
+// ReadConfigFile reads a ConfigFile from a JSON document. Unknown
+// fields are an error, so that misspelt options are not ignored.
+func ReadConfigFile(filename string) (*ConfigFile, error) {
+	c := new(ConfigFile)
+	if err := commandline.ReadJSON(filename, c); err != nil {
+		return nil, err
+	}
+	commandline.RelativePaths(filepath.Dir(filename), c.Catalogs)
+	return c, nil
+}
+
+func (c *ConfigFile) replaceRules() (commandline.ReplaceRuleList, error) {
+	var rules commandline.ReplaceRuleList
+	for _, rule := range c.Replace {
+		if err := rules.Set(rule); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// Options returns the Options set in the ConfigFile. Setting them in
+// a Config after its DefaultOptions gives the Config described by the
+// file.
+func (c *ConfigFile) Options() ([]Option, error) {
+	var opts []Option
+	if len(c.Namespaces) > 0 {
+		opts = append(opts, Namespaces(c.Namespaces...))
+	}
+	if c.PackageName != "" {
+		opts = append(opts, PackageName(c.PackageName))
+	}
+	if c.LogLevel != 0 {
+		opts = append(opts, LogLevel(c.LogLevel))
+	}
+	if c.FollowImports {
+		opts = append(opts, FollowImports(true))
+	}
+	if len(c.Catalogs) > 0 {
+		catalog, err := xsd.ReadCatalog(c.Catalogs...)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, Catalog(catalog))
+	}
+	if c.IgnoreAttributes != nil {
+		opts = append(opts, IgnoreAttributes(c.IgnoreAttributes...))
+	}
+	if c.IgnoreElements != nil {
+		opts = append(opts, IgnoreElements(c.IgnoreElements...))
+	}
+	if c.OnlyTypes != nil {
+		opts = append(opts, OnlyTypes(c.OnlyTypes...))
+	}
+	for _, name := range c.AllowTypes {
+		opts = append(opts, AllowType(parseName(name, "")))
+	}
+	rules, err := c.replaceRules()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		opts = append(opts, replaceAllNamesRegex(r.From, r.To))
+	}
+
+	for _, v := range []struct {
+		set bool
+		opt func(bool) Option
+	}{
+		{c.TypeSafeChoices, TypeSafeChoices},
+		{c.StrictEnums, StrictEnums},
+		{c.ValidateMethods, ValidateMethods},
+		{c.ArbitraryPrecision, ArbitraryPrecision},
+		{c.PreserveTimeFormat, PreserveTimeFormat},
+		{c.GroupStructs, GroupStructs},
+		{c.SubstitutionGroups, SubstitutionGroups},
+		{c.DerivedTypes, DerivedTypes},
+		{c.NillableElements, NillableElements},
+		{c.OptionalPointers, OptionalPointers},
+		{c.RootElements, RootElements},
+	} {
+		if v.set {
+			opts = append(opts, v.opt(true))
+		}
+	}
+	if c.UseFieldNames {
+		opts = append(opts, UseFieldNames())
+	}
+	if c.HandleSOAPArrayType {
+		opts = append(opts, HandleSOAPArrayType())
+	}
+	if c.SOAPArrayAsSlice {
+		opts = append(opts, SOAPArrayAsSlice())
+	}
+
+	if len(c.PackagePaths) > 0 {
+		opts = append(opts, PackagePaths(c.PackagePaths))
+	}
+	for _, ns := range commandline.Map(c.ImportPackages).Keys() {
+		opts = append(opts, ImportPackage(ns, c.ImportPackages[ns], nil))
+	}
+	for _, name := range commandline.Map(c.MapTypes).Keys() {
+		importPath, goType := splitGoType(c.MapTypes[name])
+		opts = append(opts, MapType(parseName(name, xsd.XMLName(xsd.String).Space), importPath, goType))
+	}
+	return opts, nil
+}
+
+// Parses an XML name written as {xmlns}name. A name without a
+// namespace is given the default namespace space.
+func parseName(s, space string) xml.Name {
+	if strings.HasPrefix(s, "{") {
+		if i := strings.Index(s, "}"); i > 0 {
+			return xml.Name{Space: s[1:i], Local: s[i+1:]}
+		}
+	}
+	return xml.Name{Space: space, Local: s}
+}
@@ -0,0 +1,360 @@
+package xsdgen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go/ast"
+	"sort"
+	"strconv"
+
+	"aqwari.net/xml/internal/gen"
+	"aqwari.net/xml/xsd"
+)
+
+// With the DerivedTypes option, an element whose type is a complex
+// type that other types are derived from is declared as a field of
+// an interface type, which is implemented by pointers to the base
+// type and to each derived type. The field is unmarshalled and
+// marshalled by a helper type that holds a pointer to it, and
+// chooses the Go type of its value by the element's xsi:type
+// attribute.
+type derivedTypes struct {
+	base xml.Name
+	// Go names of the interface, and of the helper types for
+	// single and plural fields.
+	name, helper, listHelper string
+	// The base type and the types derived from it, directly
+	// or indirectly.
+	types []xsd.Type
+	// Set when a field of the interface type is declared.
+	single, plural bool
+}
+
+// Collects the complex types that other complex types are derived
+// from, and picks a name for the interface of each that does not
+// collide with any type. Base types that are not declared as structs
+// in the generated code are left out, so fields of those types keep
+// their plain type.
+func (cfg *Config) addDerivedTypes(types map[xml.Name]xsd.Type) {
+	cfg.derived = make(map[xml.Name]*derivedTypes)
+	names := make([]xml.Name, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if a.Space != b.Space {
+			return a.Space < b.Space
+		}
+		return a.Local < b.Local
+	})
+
+	taken := make(map[string]bool)
+	for name := range types {
+		taken[cfg.public(name)] = true
+	}
+	ignored := make(map[xml.Name]bool)
+	for _, name := range names {
+		t, ok := types[name].(*xsd.ComplexType)
+		if !ok || t.Anonymous || t.Name != name {
+			// An anonymous type cannot be named by xsi:type.
+			// Types are also listed under the names of the
+			// elements declared with them.
+			continue
+		}
+		seen := map[xml.Name]bool{name: true}
+		for b, ok := t.Base.(*xsd.ComplexType); ok && !seen[b.Name]; b, ok = b.Base.(*xsd.ComplexType) {
+			seen[b.Name] = true
+			if ignored[b.Name] {
+				continue
+			}
+			if !cfg.declaresStruct(b) {
+				cfg.logf("base type %s is not declared as a struct, ignoring types derived from it",
+					b.Name.Local)
+				ignored[b.Name] = true
+				continue
+			}
+			d, ok := cfg.derived[b.Name]
+			if !ok {
+				d = &derivedTypes{
+					base:  b.Name,
+					name:  "Any" + cfg.public(b.Name),
+					types: []xsd.Type{b},
+				}
+				for i := 2; taken[d.name]; i++ {
+					d.name = "Any" + cfg.public(b.Name) + strconv.Itoa(i)
+				}
+				taken[d.name] = true
+				d.helper = "xsd" + d.name
+				d.listHelper = d.helper + "List"
+				cfg.derived[b.Name] = d
+			}
+			d.types = append(d.types, t)
+		}
+	}
+}
+
+// Reports whether a complex type is declared as a struct in the
+// generated code. Mapped types and the types of imported packages
+// are declared elsewhere, and a type with simple content and no
+// attributes is flattened to its base type.
+func (cfg *Config) declaresStruct(t *xsd.ComplexType) bool {
+	if _, ok := cfg.mappedType(t); ok {
+		return false
+	}
+	if _, ok := cfg.imports[t.Name.Space]; ok {
+		return false
+	}
+	switch b := t.Base.(type) {
+	case xsd.Builtin:
+		if b == xsd.AnyType {
+			return true
+		}
+	case *xsd.SimpleType:
+	default:
+		return true
+	}
+	attributes, _ := cfg.filterFields(t)
+	return len(attributes) > 0
+}
+
+// Returns the derived types of an element that may be declared as
+// a field of their interface type.
+func (cfg *Config) derivedTypesOf(el xsd.Element) *derivedTypes {
+	t, ok := el.Type.(*xsd.ComplexType)
+	if !ok || el.Wildcard {
+		return nil
+	}
+	return cfg.derived[t.Name]
+}
+
+// Returns the override used to unmarshal and marshal a field of
+// the interface type.
+func (d *derivedTypes) override(field, tag string, plural bool) fieldOverride {
+	o := fieldOverride{
+		FieldName: field,
+		FromType:  d.name,
+		ToType:    d.helper,
+		Tag:       tag,
+		Wrap:      true,
+	}
+	if plural {
+		d.plural = true
+		o.FromType = "[]" + d.name
+		o.ToType = d.listHelper
+	} else {
+		d.single = true
+	}
+	return o
+}
+
+type derivedType struct {
+	Name xml.Name
+	Type string
+}
+
+// Declares the interface, registry and helper types for each base
+// type that is used by a field, and implements the interface on the
+// base type and the types derived from it. Types that are not
+// declared as structs in the generated code are left out.
+func (cfg *Config) addDerivedSpecs(code *Code) error {
+	bases := make([]xml.Name, 0, len(cfg.derived))
+	for base, d := range cfg.derived {
+		if d.single || d.plural {
+			bases = append(bases, base)
+		}
+	}
+	sort.Slice(bases, func(i, j int) bool {
+		return cfg.derived[bases[i]].name < cfg.derived[bases[j]].name
+	})
+	for _, base := range bases {
+		d := cfg.derived[base]
+		var types []derivedType
+		for _, t := range d.types {
+			typeName := cfg.exprString(t)
+			s, ok := code.decls[typeName]
+			if _, isStruct := s.xsdType.(*xsd.ComplexType); !ok || !isStruct {
+				cfg.logf("type %s derived from %s is not declared, ignoring",
+					typeName, base.Local)
+				continue
+			}
+			types = append(types, derivedType{xsd.XMLName(t), typeName})
+		}
+		if len(types) == 0 || types[0].Name != base {
+			return fmt.Errorf("base type %s is not declared", base.Local)
+		}
+		specs, err := cfg.genDerivedTypes(d, types)
+		if err != nil {
+			return fmt.Errorf("types derived from %s: %v", base.Local, err)
+		}
+		for _, s := range specs {
+			code.decls[s.name] = s
+		}
+
+		marker := "is" + d.name
+		for _, t := range types {
+			decls, err := gen.Declarations(fmt.Sprintf("func (*%s) %s() {}", t.Type, marker))
+			if err != nil {
+				return err
+			}
+			s := code.decls[t.Type]
+			s.methods = append(s.methods, decls[0].(*ast.FuncDecl))
+			code.decls[t.Type] = s
+		}
+	}
+	return nil
+}
+
+// genDerivedTypes generates the interface implemented by a base type
+// and the types derived from it, and the helper types of its fields.
+func (cfg *Config) genDerivedTypes(d *derivedTypes, types []derivedType) ([]spec, error) {
+	var data struct {
+		Name, Helper, Registry, Base string
+		Derived                      []derivedType
+	}
+	data.Name = d.name
+	data.Helper = d.helper
+	data.Registry = d.helper + "Types"
+	data.Base = types[0].Type
+	data.Derived = types[1:]
+
+	iface := spec{
+		doc: fmt.Sprintf("%s is implemented by pointers to %s and to the types derived\n"+
+			"from it. The type of an element is chosen by its xsi:type attribute.",
+			d.name, data.Base),
+		name: d.name,
+		expr: cfg.markerInterface("is" + d.name),
+		xsdType: &xsd.ComplexType{
+			Name:      xml.Name{d.base.Space, d.name},
+			Anonymous: true,
+		},
+	}
+	result := []spec{iface}
+
+	// The registry holds a constructor for each type that may be
+	// named by the xsi:type attribute of an element.
+	registry, err := gen.Snippets(data, `
+		var {{.Registry}} = map[xml.Name]func() {{.Name}}{
+			{{- range $.Derived}}
+			{Space: {{printf "%q" .Name.Space}}, Local: {{printf "%q" .Name.Local}}}: func() {{$.Name}} { return new({{.Type}}) },
+			{{- end}}
+		}
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	unmarshal, err := gen.Func("UnmarshalXML").
+		Receiver("h "+data.Helper).
+		Args("d *xml.Decoder", "start xml.StartElement").
+		Returns("error").
+		BodyTmpl(`
+			var v {{.Name}} = new({{.Base}})
+			if name, ok := _xsiType(start); ok {
+				if fn, ok := {{.Registry}}[name]; ok {
+					v = fn()
+				} else if name.Space == "" {
+					// The prefix is declared by an ancestor, so
+					// the type is only known by its local name.
+					var match func() {{.Name}}
+					for t, fn := range {{.Registry}} {
+						if t.Local != name.Local {
+							continue
+						}
+						if match != nil {
+							return fmt.Errorf("xsi:type %s is ambiguous without the namespace of its prefix", name.Local)
+						}
+						match = fn
+					}
+					if match != nil {
+						v = match()
+					}
+				}
+			}
+			if err := d.DecodeElement(v, &start); err != nil {
+				return err
+			}
+			*h.v = v
+			return nil
+		`, data).Decl()
+	if err != nil {
+		return nil, err
+	}
+	marshal, err := gen.Func("MarshalXML").
+		Receiver("h "+data.Helper).
+		Args("e *xml.Encoder", "start xml.StartElement").
+		Returns("error").
+		BodyTmpl(`
+			switch v := (*h.v).(type) {
+			case nil:
+				return nil
+			case *{{.Base}}:
+				return e.EncodeElement(v, start)
+			{{- range .Derived}}
+			case *{{.Type}}:
+				start.Attr = append(start.Attr, _xsiTypeAttr(xml.Name{Space: {{printf "%q" .Name.Space}}, Local: {{printf "%q" .Name.Local}}})...)
+				return e.EncodeElement(v, start)
+			{{- end}}
+			}
+			return fmt.Errorf("cannot marshal %T as a {{.Base}}", *h.v)
+		`, data).Decl()
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, spec{
+		name:        d.helper,
+		expr:        gen.Struct(ast.NewIdent("v"), &ast.StarExpr{X: ast.NewIdent(d.name)}, nil),
+		private:     true,
+		decls:       registry,
+		helperFuncs: []string{"_xsiType", "_xsiTypeAttr"},
+		xsdType: &xsd.ComplexType{
+			Name:      xml.Name{d.base.Space, d.helper},
+			Anonymous: true,
+		},
+		methods: []*ast.FuncDecl{unmarshal, marshal},
+	})
+
+	if d.plural {
+		unmarshal, err = gen.Func("UnmarshalXML").
+			Receiver("h "+d.listHelper).
+			Args("d *xml.Decoder", "start xml.StartElement").
+			Returns("error").
+			Body(`
+				var v %[1]s
+				if err := (%[2]s{&v}).UnmarshalXML(d, start); err != nil {
+					return err
+				}
+				*h.v = append(*h.v, v)
+				return nil
+			`, d.name, d.helper).Decl()
+		if err != nil {
+			return nil, err
+		}
+		marshal, err = gen.Func("MarshalXML").
+			Receiver("h "+d.listHelper).
+			Args("e *xml.Encoder", "start xml.StartElement").
+			Returns("error").
+			Body(`
+				for i := range *h.v {
+					if err := (%s{&(*h.v)[i]}).MarshalXML(e, start); err != nil {
+						return err
+					}
+				}
+				return nil
+			`, d.helper).Decl()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, spec{
+			name:    d.listHelper,
+			expr:    gen.Struct(ast.NewIdent("v"), &ast.StarExpr{X: &ast.ArrayType{Elt: ast.NewIdent(d.name)}}, nil),
+			private: true,
+			xsdType: &xsd.ComplexType{
+				Name:      xml.Name{d.base.Space, d.listHelper},
+				Anonymous: true,
+			},
+			methods: []*ast.FuncDecl{unmarshal, marshal},
+		})
+	}
+	return result, nil
+}
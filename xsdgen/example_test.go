@@ -359,3 +359,241 @@ func ExampleUseFieldNames() {
 	// }
 
 }
+
+func ExampleTypeSafeChoices() {
+	doc := xsdfile(`
+	  <complexType name="contact">
+	    <choice>
+	      <element name="email" type="xs:string" />
+	      <element name="phone" type="xs:string" />
+	    </choice>
+	  </complexType>`)
+
+	var cfg xsdgen.Config
+	cfg.Option(xsdgen.TypeSafeChoices(true))
+
+	out, err := cfg.GenSource(doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s\n", out)
+
+	// Output: // Code generated by xsdgen.test. DO NOT EDIT.
+	//
+	// package ws
+	//
+	// import (
+	// 	"encoding/xml"
+	// 	"errors"
+	// 	"fmt"
+	// )
+	//
+	// type Contact struct {
+	// 	Choice ContactChoice `xml:",any"`
+	// }
+	//
+	// func (t *Contact) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// 	type T Contact
+	// 	var overlay struct{ *T }
+	// 	overlay.T = (*T)(t)
+	// 	if err := d.DecodeElement(&overlay, &start); err != nil {
+	// 		return err
+	// 	}
+	// 	if overlay.T.Choice.Which() == "" {
+	// 		return errors.New("Contact must contain one of email, phone")
+	// 	}
+	// 	return nil
+	// }
+	//
+	// // ContactChoice holds one of the elements email, phone of Contact.
+	// type ContactChoice struct {
+	// 	name  string
+	// 	value interface{}
+	// }
+	//
+	// // Which returns the name of the element held by c, or the
+	// // empty string if c is empty.
+	// func (c ContactChoice) Which() string {
+	// 	return c.name
+	// }
+	//
+	// // Email returns the value of the email element, if c holds one.
+	// func (c ContactChoice) Email() (v string, ok bool) {
+	// 	if c.name == "email" {
+	// 		v, ok = c.value.(string)
+	// 	}
+	// 	return v, ok
+	// }
+	//
+	// // SetEmail replaces the contents of c with the email element.
+	// func (c *ContactChoice) SetEmail(v string) {
+	// 	c.name, c.value = "email", v
+	// }
+	//
+	// // Phone returns the value of the phone element, if c holds one.
+	// func (c ContactChoice) Phone() (v string, ok bool) {
+	// 	if c.name == "phone" {
+	// 		v, ok = c.value.(string)
+	// 	}
+	// 	return v, ok
+	// }
+	//
+	// // SetPhone replaces the contents of c with the phone element.
+	// func (c *ContactChoice) SetPhone(v string) {
+	// 	c.name, c.value = "phone", v
+	// }
+	// func (c *ContactChoice) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// 	switch {
+	// 	case start.Name.Local == "email" && start.Name.Space == "http://www.example.com/":
+	// 		if c.name != "" {
+	// 			return fmt.Errorf("Contact may contain only one of email, phone; found %s and %s", c.name, start.Name.Local)
+	// 		}
+	// 		var v string
+	// 		if err := d.DecodeElement(&v, &start); err != nil {
+	// 			return err
+	// 		}
+	// 		c.name, c.value = "email", v
+	// 		return nil
+	// 	case start.Name.Local == "phone" && start.Name.Space == "http://www.example.com/":
+	// 		if c.name != "" {
+	// 			return fmt.Errorf("Contact may contain only one of email, phone; found %s and %s", c.name, start.Name.Local)
+	// 		}
+	// 		var v string
+	// 		if err := d.DecodeElement(&v, &start); err != nil {
+	// 			return err
+	// 		}
+	// 		c.name, c.value = "phone", v
+	// 		return nil
+	// 	}
+	// 	return d.Skip()
+	// }
+	// func (c ContactChoice) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	// 	switch c.name {
+	// 	case "email":
+	// 		start = xml.StartElement{Name: xml.Name{Space: "http://www.example.com/", Local: "email"}}
+	// 		v, _ := c.value.(string)
+	// 		return e.EncodeElement(v, start)
+	// 	case "phone":
+	// 		start = xml.StartElement{Name: xml.Name{Space: "http://www.example.com/", Local: "phone"}}
+	// 		v, _ := c.value.(string)
+	// 		return e.EncodeElement(v, start)
+	// 	}
+	// 	return errors.New("Contact must contain one of email, phone")
+	// }
+}
+
+func ExampleStrictEnums() {
+	doc := xsdfile(`
+	  <simpleType name="color">
+	    <restriction base="xs:string">
+	      <enumeration value="red" />
+	      <enumeration value="dark blue" />
+	    </restriction>
+	  </simpleType>`)
+
+	var cfg xsdgen.Config
+	cfg.Option(xsdgen.StrictEnums(true))
+
+	out, err := cfg.GenSource(doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s\n", out)
+
+	// Output: // Code generated by xsdgen.test. DO NOT EDIT.
+	//
+	// package ws
+	//
+	// import "fmt"
+	//
+	// // May be one of red, dark blue
+	// type Color string
+	//
+	// const (
+	// 	ColorRed      Color = "red"
+	// 	ColorDarkBlue Color = "dark blue"
+	// )
+	//
+	// // IsValid returns true if v is one of the enumerated values of Color.
+	// func (v Color) IsValid() bool {
+	// 	switch v {
+	// 	case ColorRed, ColorDarkBlue:
+	// 		return true
+	// 	}
+	// 	return false
+	// }
+	// func (v *Color) UnmarshalText(text []byte) error {
+	// 	if x := Color(text); x.IsValid() {
+	// 		*v = x
+	// 		return nil
+	// 	}
+	// 	return fmt.Errorf("%q is not a valid Color", text)
+	// }
+}
+
+func ExampleValidateMethods() {
+	doc := xsdfile(`
+	  <simpleType name="zipCode">
+	    <restriction base="xs:string">
+	      <pattern value="[0-9]{5}" />
+	    </restriction>
+	  </simpleType>
+	  <complexType name="address">
+	    <sequence>
+	      <element name="street" type="xs:string" />
+	      <element name="zip" type="tns:zipCode" minOccurs="0" />
+	    </sequence>
+	  </complexType>`)
+
+	var cfg xsdgen.Config
+	cfg.Option(xsdgen.ValidateMethods(true))
+
+	out, err := cfg.GenSource(doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s\n", out)
+
+	// Output: // Code generated by xsdgen.test. DO NOT EDIT.
+	//
+	// package ws
+	//
+	// import (
+	// 	"errors"
+	// 	"fmt"
+	// 	"regexp"
+	// )
+	//
+	// type Address struct {
+	// 	Street string  `xml:"http://www.example.com/ street"`
+	// 	Zip    ZipCode `xml:"http://www.example.com/ zip,omitempty"`
+	// }
+	//
+	// // Validate returns an error if t does not contain the elements and attributes
+	// // required by its schema, or if any of its values are invalid.
+	// func (t Address) Validate() error {
+	// 	if t.Street == "" {
+	// 		return errors.New("missing required element street")
+	// 	}
+	// 	if t.Zip != "" {
+	// 		if err := t.Zip.Validate(); err != nil {
+	// 			return fmt.Errorf("zip: %v", err)
+	// 		}
+	// 	}
+	// 	return nil
+	// }
+	//
+	// // Must match the pattern [0-9]{5}
+	// type ZipCode string
+	//
+	// var _ZipCodePattern = regexp.MustCompile(`^(?:[0-9]{5})$`)
+	//
+	// // Validate returns an error if v does not satisfy the restrictions
+	// // of its simple type.
+	// func (v ZipCode) Validate() error {
+	// 	if !_ZipCodePattern.MatchString(string(v)) {
+	// 		return fmt.Errorf("%q does not match pattern %s", v, "[0-9]{5}")
+	// 	}
+	// 	return nil
+	// }
+}
@@ -0,0 +1,211 @@
+package xsdgen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aqwari.net/xml/xsd"
+)
+
+// With the GroupStructs option, the members of a named <group> or
+// <attributeGroup> are declared in a struct type of their own, which
+// is embedded in the struct of each complex type that uses the group.
+// Element groups and attribute groups have separate symbol spaces.
+type groupKey struct {
+	name xml.Name
+	attr bool
+}
+
+type groupStruct struct {
+	// The Go name of the struct type
+	name string
+	// The members of the group, as a complex type
+	t *xsd.ComplexType
+	// Set once the struct type has been generated
+	done bool
+	// The names of the fields of each member element and
+	// attribute, including those of embedded groups.
+	elements, attributes map[xml.Name]string
+}
+
+// Reserves the names of a group's fields, and of the embedded field
+// itself, so that the struct embedding the group does not use them.
+func (g *groupStruct) reserve(namegen *nameGenerator) {
+	namegen.taken[g.name] = struct{}{}
+	for _, name := range g.elements {
+		namegen.taken[name] = struct{}{}
+	}
+	for _, name := range g.attributes {
+		namegen.taken[name] = struct{}{}
+	}
+}
+
+// Collects the named groups of the schema, and picks a name for the
+// struct type of each that does not collide with any type.
+func (cfg *Config) addGroups(types map[xml.Name]xsd.Type, schema []xsd.Schema) {
+	cfg.groups = make(map[groupKey]*groupStruct)
+	members := make(map[groupKey]*xsd.ComplexType)
+	var keys []groupKey
+	for _, s := range schema {
+		for name, g := range s.Groups {
+			key := groupKey{name, false}
+			if _, ok := members[key]; !ok {
+				keys = append(keys, key)
+			}
+			members[key] = &xsd.ComplexType{
+				Doc:      g.Doc,
+				Base:     xsd.AnyType,
+				Elements: g.Elements,
+				Content:  g.Content,
+			}
+		}
+		for name, g := range s.AttributeGroups {
+			key := groupKey{name, true}
+			if _, ok := members[key]; !ok {
+				keys = append(keys, key)
+			}
+			members[key] = &xsd.ComplexType{
+				Doc:        g.Doc,
+				Base:       xsd.AnyType,
+				Attributes: g.Attributes,
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.attr != b.attr {
+			return !a.attr
+		}
+		if a.name.Space != b.name.Space {
+			return a.name.Space < b.name.Space
+		}
+		return a.name.Local < b.name.Local
+	})
+
+	taken := make(map[string]bool)
+	for name := range types {
+		taken[cfg.public(name)] = true
+	}
+	for _, key := range keys {
+		t := members[key]
+		t.Name = groupTypeName(key)
+		for i := 2; taken[cfg.public(t.Name)]; i++ {
+			t.Name.Local = groupTypeName(key).Local + strconv.Itoa(i)
+		}
+		taken[cfg.public(t.Name)] = true
+		cfg.groups[key] = &groupStruct{name: cfg.public(t.Name), t: t}
+	}
+}
+
+// Element groups are named with a "Group" suffix, and attribute
+// groups with an "Attrs" suffix, unless their names already say
+// what they are.
+func groupTypeName(key groupKey) xml.Name {
+	name := key.name
+	lower := strings.ToLower(name.Local)
+	switch {
+	case strings.HasSuffix(lower, "group"):
+		// AddressGroup
+	case key.attr && (strings.HasSuffix(lower, "attrs") || strings.HasSuffix(lower, "attributes")):
+		// commonAttributes
+	case key.attr:
+		name.Local += "Attrs"
+	default:
+		name.Local += "Group"
+	}
+	return name
+}
+
+// Returns the groups whose structs can be embedded in a struct
+// with the given elements and attributes. A group's struct is
+// embedded if all of the group's members are present, with the same
+// Go types and optionality, and none of them are alternatives of a
+// choice. The specs
+// of any group structs that are generated for the first time are
+// returned as well.
+func (cfg *Config) embeddedGroups(elements []xsd.Element, attributes []xsd.Attribute, choice *choiceGroup) (map[groupKey]*groupStruct, []spec, error) {
+	var keys []groupKey
+	members := make(map[groupKey]map[xml.Name]string)
+	add := func(key groupKey, name xml.Name, signature string) {
+		if (key.name == xml.Name{}) {
+			return
+		}
+		if members[key] == nil {
+			keys = append(keys, key)
+			members[key] = make(map[xml.Name]string)
+		}
+		members[key][name] = signature
+	}
+	for _, el := range elements {
+		signature := cfg.memberSignature(el.Type, el.Plural, el.Optional || el.Nillable)
+		if choice != nil && choice.contains(el.Name) {
+			signature = ""
+		}
+		add(groupKey{el.Group, false}, el.Name, signature)
+	}
+	for _, attr := range attributes {
+		add(groupKey{attr.Group, true}, attr.Name, cfg.memberSignature(attr.Type, attr.Plural, attr.Optional))
+	}
+
+	var specs []spec
+	result := make(map[groupKey]*groupStruct)
+	for _, key := range keys {
+		g, ok := cfg.groups[key]
+		if !ok || !cfg.groupMatches(g, key.attr, members[key]) {
+			continue
+		}
+		if !g.done {
+			g.done = true
+			g.elements = make(map[xml.Name]string)
+			g.attributes = make(map[xml.Name]string)
+			s, err := cfg.genStruct(g.t, g)
+			if err != nil {
+				return nil, nil, fmt.Errorf("group %s: %v", key.name.Local, err)
+			}
+			specs = append(specs, s...)
+		}
+		result[key] = g
+	}
+	return result, specs, nil
+}
+
+// Reports whether the members of a group are exactly those with
+// the given signatures.
+func (cfg *Config) groupMatches(g *groupStruct, attr bool, members map[xml.Name]string) bool {
+	attributes, elements := cfg.filterFields(g.t)
+	want := make(map[xml.Name]string)
+	if attr {
+		for _, a := range attributes {
+			want[a.Name] = cfg.memberSignature(a.Type, a.Plural, a.Optional)
+		}
+	} else {
+		for _, el := range elements {
+			want[el.Name] = cfg.memberSignature(el.Type, el.Plural, el.Optional || el.Nillable)
+		}
+	}
+	if len(want) != len(members) {
+		return false
+	}
+	for name, signature := range want {
+		if members[name] != signature {
+			return false
+		}
+	}
+	return true
+}
+
+// Members with the same signature are declared with the same
+// Go type and struct tag options.
+func (cfg *Config) memberSignature(t xsd.Type, plural, optional bool) string {
+	s := cfg.exprString(t)
+	if plural {
+		s = "[]" + s
+	}
+	if optional {
+		s += ",omitempty"
+	}
+	return s
+}
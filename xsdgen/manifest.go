@@ -0,0 +1,97 @@
+package xsdgen
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+
+	"aqwari.net/xml/xsd"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// A Manifest lists the Go names of the types declared for the XML
+// types of each namespace, keyed by namespace and then by the local
+// names of the XML types. It is written alongside generated code, so
+// that code generated later from schema importing the same namespaces
+// can refer to the types with the ImportPackage option.
+type Manifest map[string]map[string]string
+
+// Manifest returns the types declared in the generated code. Helper
+// types, and the types of the XML Schema namespace, are left out.
+func (code *Code) Manifest() Manifest {
+	schemaNS := xsd.XMLName(xsd.String).Space
+	m := make(Manifest)
+	for name, goName := range code.names {
+		s, ok := code.decls[goName]
+		if !ok || s.private || name.Space == schemaNS {
+			continue
+		}
+		if m[name.Space] == nil {
+			m[name.Space] = make(map[string]string)
+		}
+		m[name.Space][name.Local] = goName
+	}
+	return m
+}
+
+// The types of an imported namespace are declared in another package.
+type importedPackage struct {
+	path, name string
+	// Go names of the package's types, from its manifest. If nil,
+	// the names are not checked.
+	types map[string]string
+}
+
+// Returns the Go name of a type in an imported package, qualified
+// with the package's name, and whether the package declares it.
+func (imp importedPackage) typeName(cfg *Config, t xsd.Type) (string, bool) {
+	name := xsd.XMLName(t)
+	if imp.types == nil {
+		return imp.name + "." + cfg.public(name), true
+	}
+	goName, ok := imp.types[name.Local]
+	return imp.name + "." + goName, ok
+}
+
+// Adds import declarations for the imported packages, and the
+// packages of mapped types, that are referred to in a file.
+func (cfg *Config) addImports(fileset *token.FileSet, file *ast.File) {
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok {
+				used[x.Name] = true
+			}
+		case *ast.Ident:
+			if i := strings.Index(n.Name, "."); i > 0 {
+				used[n.Name[:i]] = true
+			}
+		}
+		return true
+	})
+	var paths []string
+	names := make(map[string]string)
+	for _, imp := range cfg.imports {
+		if used[imp.name] && names[imp.path] == "" {
+			paths = append(paths, imp.path)
+			names[imp.path] = imp.name
+		}
+	}
+	for _, m := range cfg.typeMap {
+		if m.path != "" && used[m.name] && names[m.path] == "" {
+			paths = append(paths, m.path)
+			names[m.path] = m.name
+		}
+	}
+	sort.Strings(paths)
+	for _, importPath := range paths {
+		name := names[importPath]
+		if name == path.Base(importPath) {
+			name = ""
+		}
+		astutil.AddNamedImport(fileset, file, name, importPath)
+	}
+}
@@ -0,0 +1,218 @@
+package xsdgen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go/ast"
+	"sort"
+	"strconv"
+
+	"aqwari.net/xml/internal/gen"
+	"aqwari.net/xml/xsd"
+)
+
+// With the NillableElements option, a nillable element is declared
+// as a field of a wrapper type, which records whether the element
+// carried xsi:nil="true" alongside its value. Optional elements are
+// declared as pointers to the wrapper, so that an absent element, a
+// nil element and an empty element can be told apart. There is one
+// wrapper type for each type of nillable element.
+type nillableType struct {
+	// Go name of the wrapper
+	name string
+	t    xsd.Type
+	// Set if values of the type have a Validate method.
+	validate bool
+	// Set when a field of the wrapper type is declared.
+	used bool
+}
+
+// Collects the types of the nillable elements of all complex types,
+// and picks a name for the wrapper of each that does not collide with
+// any type.
+func (cfg *Config) addNillableTypes(types map[xml.Name]xsd.Type) {
+	cfg.nillables = make(map[xml.Name]*nillableType)
+	elements := make(map[xml.Name]xsd.Type)
+	var names []xml.Name
+	for _, t := range types {
+		c, ok := t.(*xsd.ComplexType)
+		if !ok {
+			continue
+		}
+		for _, el := range c.Elements {
+			name := xsd.XMLName(el.Type)
+			if _, ok := elements[name]; !ok && el.Nillable && !el.Wildcard {
+				names = append(names, name)
+				elements[name] = el.Type
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if a.Space != b.Space {
+			return a.Space < b.Space
+		}
+		return a.Local < b.Local
+	})
+
+	taken := make(map[string]bool)
+	for name := range types {
+		taken[cfg.public(name)] = true
+	}
+	for _, name := range names {
+		n := &nillableType{
+			name:     "Nillable" + cfg.public(name),
+			t:        elements[name],
+			validate: cfg.hasValidate(elements[name]),
+		}
+		for i := 2; taken[n.name]; i++ {
+			n.name = "Nillable" + cfg.public(name) + strconv.Itoa(i)
+		}
+		taken[n.name] = true
+		cfg.nillables[name] = n
+	}
+}
+
+// Returns the wrapper type of a nillable element, or nil if the
+// element is not declared with one.
+func (cfg *Config) nillableTypeOf(el xsd.Element) *nillableType {
+	if !el.Nillable || el.Wildcard {
+		return nil
+	}
+	n, ok := cfg.nillables[xsd.XMLName(el.Type)]
+	if !ok {
+		cfg.logf("no wrapper type for nillable element %s", el.Name.Local)
+		return nil
+	}
+	n.used = true
+	return n
+}
+
+// Returns the check of a field declared with a wrapper type. A nil
+// element is present, so only plural fields can be missing.
+func (n *nillableType) fieldCheck(field string, el xsd.Element) (fieldCheck, bool) {
+	check := fieldCheck{
+		Field:    field,
+		Kind:     "element",
+		Label:    el.Name.Local,
+		Plural:   el.Plural,
+		Validate: n.validate,
+	}
+	switch {
+	case el.Plural:
+		check.Required = !el.Optional
+		check.Empty = fmt.Sprintf("len(t.%s) == 0", field)
+	case el.Optional:
+		check.Empty = fmt.Sprintf("t.%s == nil", field)
+		check.Present = fmt.Sprintf("t.%s != nil", field)
+	}
+	return check, check.Required || check.Validate
+}
+
+// Declares the wrapper types that are used by a field.
+func (cfg *Config) addNillableSpecs(code *Code) error {
+	for _, n := range cfg.nillables {
+		if !n.used {
+			continue
+		}
+		s, err := cfg.genNillableType(n)
+		if err != nil {
+			return fmt.Errorf("nillable %s: %v", n.name, err)
+		}
+		code.decls[s.name] = s
+	}
+	return nil
+}
+
+// genNillableType generates the wrapper type of a nillable element.
+// Values with a helper type are unmarshalled and marshalled through
+// it, like the fields of a complex type.
+func (cfg *Config) genNillableType(n *nillableType) (spec, error) {
+	var data struct {
+		Name, Type, Value string
+	}
+	data.Name = n.name
+	data.Type = cfg.exprString(n.t)
+	data.Value = "&v.Value"
+
+	base, err := cfg.expr(n.t)
+	if err != nil {
+		return spec{}, err
+	}
+	s := spec{
+		doc: fmt.Sprintf("%s holds the value of a nillable element of type %s.\n"+
+			"Nil is true if the element has the attribute xsi:nil=\"true\", in\n"+
+			"which case Value is not used.", n.name, data.Type),
+		name:        n.name,
+		expr:        gen.Struct(ast.NewIdent("Value"), base, nil, ast.NewIdent("Nil"), ast.NewIdent("bool"), nil),
+		helperFuncs: []string{"_xsiNil"},
+		xsdType: &xsd.ComplexType{
+			Name:      xml.Name{xsd.XMLName(n.t).Space, n.name},
+			Anonymous: true,
+		},
+	}
+	if cfg.usesHelper(n.t) {
+		h, ok := cfg.helperTypes[xsd.XMLName(n.t)]
+		if !ok {
+			return spec{}, fmt.Errorf("no helper type for type %s", data.Type)
+		}
+		s.helperTypes = append(s.helperTypes, xsd.XMLName(n.t))
+		data.Value = fmt.Sprintf("(*%s)(&v.Value)", h.name)
+	}
+
+	unmarshal, err := gen.Func("UnmarshalXML").
+		Receiver("v *"+data.Name).
+		Args("d *xml.Decoder", "start xml.StartElement").
+		Returns("error").
+		BodyTmpl(`
+			*v = {{.Name}}{}
+			if _xsiNil(start) {
+				v.Nil = true
+				return d.Skip()
+			}
+			return d.DecodeElement({{.Value}}, &start)
+		`, data).Decl()
+	if err != nil {
+		return spec{}, err
+	}
+	marshal, err := gen.Func("MarshalXML").
+		Receiver("v "+data.Name).
+		Args("e *xml.Encoder", "start xml.StartElement").
+		Returns("error").
+		BodyTmpl(`
+			if !v.Nil {
+				return e.EncodeElement({{.Value}}, start)
+			}
+			// The encoder writes attribute names without a namespace
+			// as they are, so that the prefix can be declared.
+			start.Attr = append(start.Attr,
+				xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"},
+				xml.Attr{Name: xml.Name{Local: "xsi:nil"}, Value: "true"})
+			if err := e.EncodeToken(start); err != nil {
+				return err
+			}
+			return e.EncodeToken(start.End())
+		`, data).Decl()
+	if err != nil {
+		return spec{}, err
+	}
+	s.methods = append(s.methods, unmarshal, marshal)
+
+	if cfg.validateMethods && n.validate {
+		validate, err := gen.Func("Validate").
+			Receiver("v " + data.Name).
+			Returns("error").
+			Comment("Validate returns an error if v is not nil and its value is invalid.").
+			Body(`
+				if v.Nil {
+					return nil
+				}
+				return v.Value.Validate()
+			`).Decl()
+		if err != nil {
+			return spec{}, err
+		}
+		s.methods = append(s.methods, validate)
+	}
+	return s, nil
+}
@@ -0,0 +1,388 @@
+package xsdgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"aqwari.net/xml/internal/gen"
+	"aqwari.net/xml/xsd"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// With the PackagePaths option, the types of each namespace are
+// declared in a package of their own, and refer to the types of other
+// namespaces through their packages. Helper types and functions, and
+// the types of the XML Schema namespace, such as NillableString, are
+// declared in each package that uses them.
+type goPackage struct {
+	path, name string
+	// names of the specs declared in the package
+	specs []string
+	// the helper functions declared in the package
+	helpers []string
+	// import paths of the packages that are referred to
+	imports map[string]bool
+}
+
+// GenPackages generates the source of a Go package for the types of
+// each namespace in the PackagePaths option, keyed by the import path
+// of the package. Namespaces mapped to the same import path share a
+// package. It is an error for a declared type to be in a namespace
+// without an import path, and for packages to import each other.
+func (code *Code) GenPackages() (map[string][]byte, error) {
+	cfg := code.cfg
+	if len(cfg.packagePaths) == 0 {
+		return nil, fmt.Errorf("no package paths for namespaces %q", cfg.namespaces)
+	}
+	schemaNS := xsd.XMLName(xsd.String).Space
+
+	// The package of each top-level name. Shared names are left
+	// out, as they are declared in every package that uses them.
+	owner := make(map[string]string)
+	packages := make(map[string]*goPackage)
+	var shared []string
+	missing := make(map[string]bool)
+	names := code.decls.keys()
+	sort.Strings(names)
+	for _, name := range names {
+		s := code.decls[name]
+		ns := xsd.XMLName(s.xsdType).Space
+		if ns == schemaNS {
+			shared = append(shared, name)
+			continue
+		}
+		importPath, ok := cfg.packagePaths[ns]
+		if !ok {
+			missing[ns] = true
+			continue
+		}
+		pkg, ok := packages[importPath]
+		if !ok {
+			pkg = &goPackage{
+				path:    importPath,
+				name:    packageName(importPath),
+				imports: make(map[string]bool),
+			}
+			packages[importPath] = pkg
+		}
+		pkg.specs = append(pkg.specs, name)
+		owner[name] = importPath
+		for _, fn := range s.methods {
+			if fn.Recv == nil && code.helpers[fn.Name.Name] != fn {
+				owner[fn.Name.Name] = importPath
+			}
+		}
+		for _, decl := range s.decls {
+			if decl, ok := decl.(*ast.GenDecl); ok {
+				for _, v := range decl.Specs {
+					if v, ok := v.(*ast.ValueSpec); ok {
+						for _, id := range v.Names {
+							owner[id.Name] = importPath
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(missing) > 0 {
+		var list []string
+		for ns := range missing {
+			list = append(list, ns)
+		}
+		sort.Strings(list)
+		return nil, fmt.Errorf("no package path for namespaces %q", list)
+	}
+
+	// The shared specs and helper functions used by each package,
+	// including those used by other shared specs.
+	for _, pkg := range packages {
+		added := make(map[string]bool)
+		helpers := make(map[string]bool)
+		for i := 0; i < len(pkg.specs); i++ {
+			s := code.decls[pkg.specs[i]]
+			for _, name := range s.helperFuncs {
+				helpers[name] = true
+			}
+			for _, name := range shared {
+				if !added[name] && refersTo(s, name) {
+					added[name] = true
+					pkg.specs = append(pkg.specs, name)
+				}
+			}
+		}
+		for name := range helpers {
+			if _, ok := code.helpers[name]; ok {
+				pkg.helpers = append(pkg.helpers, name)
+			}
+		}
+		sort.Strings(pkg.specs)
+		sort.Strings(pkg.helpers)
+	}
+
+	paths := make([]string, 0, len(packages))
+	for importPath := range packages {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+	if err := checkMarkerMethods(code, paths, packages); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*ast.File)
+	fileset := token.NewFileSet()
+	for _, importPath := range paths {
+		pkg := packages[importPath]
+		file, err := code.parsePackage(fileset, pkg)
+		if err != nil {
+			return nil, fmt.Errorf("package %s: %v", importPath, err)
+		}
+		if err := pkg.qualify(file, owner, packages); err != nil {
+			return nil, fmt.Errorf("package %s: %v", importPath, err)
+		}
+		files[importPath] = file
+	}
+	if err := checkImportCycles(paths, packages); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte)
+	for importPath, file := range files {
+		pkg := packages[importPath]
+		for _, dep := range pkg.importPaths() {
+			name := packages[dep].name
+			if name == path.Base(dep) {
+				name = ""
+			}
+			astutil.AddNamedImport(fileset, file, name, dep)
+		}
+		cfg.addImports(fileset, file)
+		src, err := gen.FormattedFileSource(fileset, file, path.Base(importPath)+".go")
+		if err != nil {
+			return nil, fmt.Errorf("package %s: %v", importPath, err)
+		}
+		result[importPath] = src
+	}
+	return result, nil
+}
+
+// Declares the specs and helper functions of a package in a file,
+// which is printed and read back in so that its identifiers can be
+// changed without changing the specs.
+func (code *Code) parsePackage(fileset *token.FileSet, pkg *goPackage) (*ast.File, error) {
+	file := &ast.File{Name: ast.NewIdent(pkg.name)}
+	for _, name := range pkg.specs {
+		info := code.decls[name]
+		file.Decls = append(file.Decls, &ast.GenDecl{
+			Doc: gen.CommentGroup(info.doc),
+			Tok: token.TYPE,
+			Specs: []ast.Spec{
+				&ast.TypeSpec{
+					Name: ast.NewIdent(name),
+					Type: info.expr,
+				},
+			},
+		})
+		file.Decls = append(file.Decls, info.decls...)
+		for _, f := range info.methods {
+			if f.Recv == nil && code.helpers[f.Name.Name] == f {
+				continue
+			}
+			file.Decls = append(file.Decls, f)
+		}
+	}
+	for _, name := range pkg.helpers {
+		file.Decls = append(file.Decls, code.helpers[name])
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), file); err != nil {
+		return nil, err
+	}
+	return parser.ParseFile(fileset, path.Base(pkg.path)+".go", buf.Bytes(), parser.ParseComments)
+}
+
+// Qualifies references to the names declared in other packages with
+// the names of those packages, and records the imports of a package.
+// Only names used as types, or called as functions and conversions,
+// are qualified, unless they are declared within the file, such as
+// the local types and variables of a method. Unexported names cannot
+// be referred to.
+func (pkg *goPackage) qualify(file *ast.File, owner map[string]string, packages map[string]*goPackage) error {
+	var err error
+	typeCases := make(map[ast.Node]bool)
+	astutil.Apply(file, func(c *astutil.Cursor) bool {
+		if err != nil {
+			return false
+		}
+		if sw, ok := c.Node().(*ast.TypeSwitchStmt); ok {
+			for _, clause := range sw.Body.List {
+				typeCases[clause] = true
+			}
+		}
+		id, ok := c.Node().(*ast.Ident)
+		if !ok || id.Obj != nil || !isTypeOrCall(c, typeCases) {
+			return true
+		}
+		dep, ok := owner[id.Name]
+		if !ok || dep == pkg.path {
+			return true
+		}
+		if !ast.IsExported(id.Name) {
+			err = fmt.Errorf("%s refers to unexported name %s of package %s", pkg.path, id.Name, dep)
+			return false
+		}
+		pkg.imports[dep] = true
+		c.Replace(&ast.SelectorExpr{
+			X:   ast.NewIdent(packages[dep].name),
+			Sel: ast.NewIdent(id.Name),
+		})
+		return true
+	}, nil)
+	return err
+}
+
+// Reports whether the node at c is in the position of a type, such as
+// the type of a field or the element type of a slice, or is the
+// function of a call or conversion.
+func isTypeOrCall(c *astutil.Cursor, typeCases map[ast.Node]bool) bool {
+	switch parent := c.Parent().(type) {
+	case *ast.Field, *ast.CompositeLit, *ast.TypeSpec, *ast.ValueSpec, *ast.TypeAssertExpr:
+		return c.Name() == "Type"
+	case *ast.StarExpr, *ast.Ellipsis:
+		return true
+	case *ast.ArrayType:
+		return c.Name() == "Elt"
+	case *ast.MapType:
+		return c.Name() == "Key" || c.Name() == "Value"
+	case *ast.ChanType:
+		return c.Name() == "Value"
+	case *ast.CallExpr:
+		if c.Name() == "Fun" {
+			return true
+		}
+		// The type passed to new or make
+		fn, ok := parent.Fun.(*ast.Ident)
+		return ok && (fn.Name == "new" || fn.Name == "make") && c.Index() == 0
+	case *ast.CaseClause:
+		return typeCases[parent]
+	}
+	return false
+}
+
+// The interfaces declared by the DerivedTypes and SubstitutionGroups
+// options have unexported methods, which cannot be implemented by the
+// types of another package.
+func checkMarkerMethods(code *Code, paths []string, packages map[string]*goPackage) error {
+	iface := make(map[string]string)
+	for _, pkg := range packages {
+		for _, name := range pkg.specs {
+			t, ok := code.decls[name].expr.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+			for _, m := range t.Methods.List {
+				for _, id := range m.Names {
+					if !ast.IsExported(id.Name) {
+						iface[id.Name] = pkg.path
+					}
+				}
+			}
+		}
+	}
+	for _, importPath := range paths {
+		pkg := packages[importPath]
+		for _, name := range pkg.specs {
+			for _, fn := range code.decls[name].methods {
+				dep, ok := iface[fn.Name.Name]
+				if fn.Recv != nil && ok && dep != pkg.path {
+					return fmt.Errorf("type %s of package %s cannot implement %s of package %s; "+
+						"use the same package for both namespaces",
+						name, pkg.path, strings.TrimPrefix(fn.Name.Name, "is"), dep)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (pkg *goPackage) importPaths() []string {
+	result := make([]string, 0, len(pkg.imports))
+	for dep := range pkg.imports {
+		result = append(result, dep)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Go packages cannot import each other, so namespaces whose types
+// refer to each other must share a package.
+func checkImportCycles(paths []string, packages map[string]*goPackage) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var stack []string
+	var visit func(string) error
+	visit = func(importPath string) error {
+		switch state[importPath] {
+		case visiting:
+			for i, p := range stack {
+				if p == importPath {
+					cycle := append(stack[i:], importPath)
+					return fmt.Errorf("import cycle %s; use the same package for their namespaces",
+						strings.Join(cycle, " -> "))
+				}
+			}
+		case done:
+			return nil
+		}
+		state[importPath] = visiting
+		stack = append(stack, importPath)
+		for _, dep := range packages[importPath].importPaths() {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[importPath] = done
+		return nil
+	}
+	for _, importPath := range paths {
+		if err := visit(importPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// The name of a package is the last element of its import path,
+// without any characters that are not allowed in identifiers.
+func packageName(importPath string) string {
+	elems := strings.Split(importPath, "/")
+	base := elems[len(elems)-1]
+	if majorVersion.MatchString(base) && len(elems) > 1 {
+		base = elems[len(elems)-2]
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, base)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "ns" + name
+	}
+	return name
+}
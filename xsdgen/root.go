@@ -0,0 +1,223 @@
+package xsdgen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go/ast"
+	"sort"
+
+	"aqwari.net/xml/internal/gen"
+	"aqwari.net/xml/xsd"
+)
+
+// With the RootElements option, each global element of the target
+// namespaces is declared as a wrapper type with an XMLName field,
+// which can be decoded from and encoded to a document whose root is
+// the element. The wrapper embeds the type of the element if it is
+// declared as a struct, and holds it in a Value field otherwise.
+type rootElement struct {
+	Name xml.Name
+	// Go names of the wrapper, and of the element's type
+	Type, Elem string
+	// The field holding the element's value, and an expression
+	// for a pointer to it that can be decoded and encoded.
+	Field, Value string
+	// Set if the element's type has a constructor.
+	New bool
+}
+
+// Declares a wrapper type for each global element of the primary
+// schema, after all other types have been declared. Elements that
+// are abstract, or whose types are not declared, are left out.
+func (cfg *Config) addRootElements(code *Code, primaries []xsd.Schema) error {
+	var elements []*xsd.Element
+	for _, primary := range primaries {
+		for _, el := range primary.Elements {
+			elements = append(elements, el)
+		}
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		a, b := elements[i].Name, elements[j].Name
+		if a.Space != b.Space {
+			return a.Space < b.Space
+		}
+		return a.Local < b.Local
+	})
+
+	taken := make(map[string]bool)
+	for name, s := range code.decls {
+		taken[name] = true
+		for _, fn := range s.methods {
+			if fn.Recv == nil {
+				taken[fn.Name.Name] = true
+			}
+		}
+	}
+	// The wrapper's Parse function must not collide with the
+	// constructor of any type, either.
+	used := func(name string) bool {
+		return taken[name] || taken["Parse"+name]
+	}
+	for _, el := range elements {
+		if el.Abstract || el.Type == nil || el.Type == xsd.AnyType {
+			continue
+		}
+		root := rootElement{
+			Name: el.Name,
+			Type: cfg.public(el.Name),
+			Elem: cfg.exprString(el.Type),
+		}
+		if used(root.Type) {
+			root.Type += "Document"
+		}
+		if used(root.Type) {
+			cfg.logf("wrapper %s of element %s is already declared, ignoring",
+				root.Type, el.Name.Local)
+			continue
+		}
+
+		var helperTypes []xml.Name
+		switch t := el.Type.(type) {
+		case *xsd.ComplexType:
+			s, ok := code.decls[root.Elem]
+			if _, isStruct := s.xsdType.(*xsd.ComplexType); !ok || !isStruct {
+				cfg.logf("type %s of element %s is not declared, ignoring",
+					root.Elem, el.Name.Local)
+				continue
+			}
+			root.Field = root.Elem
+			root.Value = "&t." + root.Elem
+			for _, fn := range s.methods {
+				if fn.Recv == nil && fn.Name.Name == "New"+root.Elem {
+					root.New = true
+				}
+			}
+		case *xsd.SimpleType:
+			if _, ok := code.decls[root.Elem]; !ok {
+				cfg.logf("type %s of element %s is not declared, ignoring",
+					root.Elem, el.Name.Local)
+				continue
+			}
+			root.Field = "Value"
+			root.Value = "&t.Value"
+		case xsd.Builtin:
+			root.Field = "Value"
+			root.Value = "&t.Value"
+			if cfg.usesHelper(t) {
+				h, ok := cfg.helperTypes[xsd.XMLName(t)]
+				if !ok {
+					return fmt.Errorf("element %s: no helper type for type %s",
+						el.Name.Local, root.Elem)
+				}
+				helperTypes = append(helperTypes, xsd.XMLName(t))
+				root.Value = fmt.Sprintf("(*%s)(&t.Value)", h.name)
+			} else if expr, err := cfg.expr(t); err != nil {
+				return err
+			} else if _, ok := expr.(*ast.ArrayType); ok {
+				cfg.logf("element %s: cannot declare a root element of list type %s",
+					el.Name.Local, root.Elem)
+				continue
+			}
+		default:
+			continue
+		}
+		taken[root.Type] = true
+		taken["Parse"+root.Type] = true
+
+		s, err := cfg.genRootElement(root)
+		if err != nil {
+			return fmt.Errorf("element %s: %v", el.Name.Local, err)
+		}
+		s.helperTypes = helperTypes
+		code.decls[s.name] = s
+	}
+	return nil
+}
+
+// genRootElement generates the wrapper type of a global element, and
+// the functions that decode and encode documents with it.
+func (cfg *Config) genRootElement(root rootElement) (spec, error) {
+	var field ast.Expr
+	if root.Field == "Value" {
+		field = ast.NewIdent("Value")
+	}
+	expr := gen.Struct(
+		ast.NewIdent("XMLName"), ast.NewIdent("xml.Name"),
+		gen.String(fmt.Sprintf(`xml:"%s %s"`, root.Name.Space, root.Name.Local)),
+		field, ast.NewIdent(root.Elem), nil)
+	if root.Name.Space == "" {
+		expr.Fields.List[0].Tag = gen.String(fmt.Sprintf(`xml:"%s"`, root.Name.Local))
+	}
+	s := spec{
+		doc: fmt.Sprintf("%s is a document whose root is the %s element.",
+			root.Type, root.Name.Local),
+		name: root.Type,
+		expr: expr,
+		xsdType: &xsd.ComplexType{
+			Name:      xml.Name{root.Name.Space, root.Type},
+			Anonymous: true,
+		},
+	}
+
+	unmarshal, err := gen.Func("UnmarshalXML").
+		Receiver("t *"+root.Type).
+		Args("d *xml.Decoder", "start xml.StartElement").
+		Returns("error").
+		BodyTmpl(`
+			if start.Name != (xml.Name{Space: {{printf "%q" .Name.Space}}, Local: {{printf "%q" .Name.Local}}}) {
+				return fmt.Errorf("unexpected root element {%s}%s, want {{.Name.Local}}", start.Name.Space, start.Name.Local)
+			}
+			t.XMLName = start.Name
+			return d.DecodeElement({{.Value}}, &start)
+		`, root).Decl()
+	if err != nil {
+		return spec{}, err
+	}
+	marshal, err := gen.Func("MarshalXML").
+		Receiver("t "+root.Type).
+		Args("e *xml.Encoder", "start xml.StartElement").
+		Returns("error").
+		BodyTmpl(`
+			start.Name = xml.Name{Space: {{printf "%q" .Name.Space}}, Local: {{printf "%q" .Name.Local}}}
+			return e.EncodeElement({{.Value}}, start)
+		`, root).Decl()
+	if err != nil {
+		return spec{}, err
+	}
+	writeTo, err := gen.Func("WriteTo").
+		Receiver("t "+root.Type).
+		Args("w io.Writer").
+		Returns("int64", "error").
+		Comment("WriteTo writes t to w as an XML document, preceded by the XML declaration.").
+		Body(`
+			buf, err := xml.Marshal(t)
+			if err != nil {
+				return 0, err
+			}
+			n, err := w.Write(append([]byte(xml.Header), buf...))
+			return int64(n), err
+		`).Decl()
+	if err != nil {
+		return spec{}, err
+	}
+	parse, err := gen.Func("Parse"+root.Type).
+		Args("r io.Reader").
+		Returns("*"+root.Type, "error").
+		Comment(fmt.Sprintf("Parse%s decodes a document whose root is the %s element from r.",
+			root.Type, root.Name.Local)).
+		BodyTmpl(`
+			t := new({{.Type}})
+			{{- if .New}}
+			t.{{.Field}} = *New{{.Elem}}()
+			{{- end}}
+			if err := xml.NewDecoder(r).Decode(t); err != nil {
+				return nil, err
+			}
+			return t, nil
+		`, root).Decl()
+	if err != nil {
+		return spec{}, err
+	}
+	s.methods = append(s.methods, unmarshal, marshal, writeTo, parse)
+	return s, nil
+}
@@ -0,0 +1,398 @@
+package xsdgen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go/ast"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aqwari.net/xml/internal/gen"
+	"aqwari.net/xml/xsd"
+)
+
+// With the SubstitutionGroups option, an element that is the head of
+// a substitution group is declared as a field of an interface type,
+// which is implemented by the types of the elements that may appear
+// in its place. The field is unmarshalled and marshalled by a helper
+// type that holds a pointer to it, and chooses the Go type of each
+// element by its name. When more than one member of a group has the
+// same type, the later members are held by a struct type embedding
+// it, so that each is marshalled with its own name.
+type substitutionGroup struct {
+	head xml.Name
+	// Go type of the head element
+	headType string
+	// Go names of the interface, and of the helper types for
+	// single and plural fields.
+	name, helper, listHelper string
+	// The elements that may appear in place of the head, including
+	// the head itself unless it is abstract.
+	members []xsd.Element
+	// Set when a field of the interface type is declared.
+	single, plural bool
+}
+
+type substitute struct {
+	Name xml.Name
+	Type string
+}
+
+// Collects the substitution groups of all elements in the schema,
+// and picks a name for the interface of each that does not collide
+// with any type.
+func (cfg *Config) addSubstitutionGroups(types map[xml.Name]xsd.Type, schema []xsd.Schema) {
+	cfg.substitutions = make(map[xml.Name]*substitutionGroup)
+	elements := make(map[xml.Name]*xsd.Element)
+	var names []xml.Name
+	for _, s := range schema {
+		for name, el := range s.Elements {
+			if _, ok := elements[name]; !ok {
+				names = append(names, name)
+			}
+			elements[name] = el
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if a.Space != b.Space {
+			return a.Space < b.Space
+		}
+		return a.Local < b.Local
+	})
+
+	taken := make(map[string]bool)
+	for name := range types {
+		taken[cfg.public(name)] = true
+	}
+	group := func(head xml.Name) *substitutionGroup {
+		if g, ok := cfg.substitutions[head]; ok {
+			return g
+		}
+		el := elements[head]
+		g := &substitutionGroup{
+			head:     head,
+			headType: cfg.exprString(el.Type),
+			name:     cfg.public(head) + "Element",
+		}
+		for i := 2; taken[g.name]; i++ {
+			g.name = cfg.public(head) + "Element" + strconv.Itoa(i)
+		}
+		taken[g.name] = true
+		g.helper = "xsd" + g.name
+		g.listHelper = g.helper + "List"
+		if !el.Abstract {
+			g.members = append(g.members, *el)
+		}
+		cfg.substitutions[head] = g
+		return g
+	}
+	for _, name := range names {
+		el := elements[name]
+		if el.Abstract {
+			continue
+		}
+		// Members of a substitution group are members of the
+		// group of its head, as well.
+		seen := map[xml.Name]bool{name: true}
+		for head := el.SubstitutionGroup; head.Local != "" && !seen[head]; head = elements[head].SubstitutionGroup {
+			if _, ok := elements[head]; !ok {
+				cfg.logf("element %s: substitution group head %s not found",
+					name.Local, head.Local)
+				break
+			}
+			seen[head] = true
+			g := group(head)
+			g.members = append(g.members, *el)
+		}
+	}
+}
+
+// Returns the substitution group of an element that may be
+// declared as a field of its interface type.
+func (cfg *Config) substitutionGroupOf(el xsd.Element) *substitutionGroup {
+	g, ok := cfg.substitutions[el.Name]
+	if !ok || el.Wildcard || cfg.exprString(el.Type) != g.headType {
+		return nil
+	}
+	return g
+}
+
+// Returns the override used to unmarshal and marshal a field of
+// the group's interface type.
+func (g *substitutionGroup) override(field, tag string, plural bool) fieldOverride {
+	o := fieldOverride{
+		FieldName: field,
+		FromType:  g.name,
+		ToType:    g.helper,
+		Tag:       tag,
+		Wrap:      true,
+	}
+	if plural {
+		g.plural = true
+		o.FromType = "[]" + g.name
+		o.ToType = g.listHelper
+	} else {
+		g.single = true
+	}
+	return o
+}
+
+// Declares the interface and helper types of each substitution group
+// that is used by a field, and implements the interface on the types
+// of its members. Members whose types are not declared as structs in
+// the generated code are left out.
+func (cfg *Config) addSubstitutionSpecs(code *Code) error {
+	// Struct types embedding the type of an element, for members
+	// of a group that share their type with another member.
+	wrappers := make(map[xml.Name]string)
+	heads := make([]xml.Name, 0, len(cfg.substitutions))
+	for head, g := range cfg.substitutions {
+		if g.single || g.plural {
+			heads = append(heads, head)
+		}
+	}
+	sort.Slice(heads, func(i, j int) bool {
+		return cfg.substitutions[heads[i]].name < cfg.substitutions[heads[j]].name
+	})
+	for _, head := range heads {
+		g := cfg.substitutions[head]
+		var members []substitute
+		used := make(map[string]bool)
+		for _, el := range g.members {
+			typeName := cfg.exprString(el.Type)
+			s, ok := code.decls[typeName]
+			if _, isStruct := s.xsdType.(*xsd.ComplexType); !ok || !isStruct {
+				cfg.logf("substitution group %s: type %s of element %s is not declared, ignoring",
+					head.Local, typeName, el.Name.Local)
+				continue
+			}
+			if used[typeName] {
+				typeName = cfg.substituteWrapper(code, wrappers, el.Name, typeName)
+			} else {
+				used[typeName] = true
+			}
+			members = append(members, substitute{el.Name, typeName})
+		}
+		specs, err := cfg.genSubstitutionGroup(g, members)
+		if err != nil {
+			return fmt.Errorf("substitution group %s: %v", head.Local, err)
+		}
+		for _, s := range specs {
+			code.decls[s.name] = s
+		}
+
+		marker := "is" + g.name
+		implemented := make(map[string]bool)
+		for _, m := range members {
+			if implemented[m.Type] || wrappers[m.Name] == m.Type {
+				// Wrappers implement the interface through
+				// the type they embed.
+				continue
+			}
+			implemented[m.Type] = true
+			decls, err := gen.Declarations(fmt.Sprintf("func (%s) %s() {}", m.Type, marker))
+			if err != nil {
+				return err
+			}
+			s := code.decls[m.Type]
+			s.methods = append(s.methods, decls[0].(*ast.FuncDecl))
+			code.decls[m.Type] = s
+		}
+	}
+	return nil
+}
+
+// Declares a struct type embedding typeName for the element name,
+// named after the element, and returns its name.
+func (cfg *Config) substituteWrapper(code *Code, wrappers map[xml.Name]string, name xml.Name, typeName string) string {
+	if w, ok := wrappers[name]; ok {
+		return w
+	}
+	w := cfg.public(name)
+	if _, ok := code.decls[w]; ok {
+		w += "Element"
+	}
+	for i := 2; ; i++ {
+		if _, ok := code.decls[w]; !ok {
+			break
+		}
+		w = cfg.public(name) + "Element" + strconv.Itoa(i)
+	}
+	wrappers[name] = w
+	code.decls[w] = spec{
+		doc: fmt.Sprintf("A %s holds a %s element, which has the same type as\n"+
+			"other elements of its substitution group.", w, name.Local),
+		name: w,
+		expr: &ast.StructType{Fields: &ast.FieldList{
+			List: []*ast.Field{{Type: ast.NewIdent(typeName)}},
+		}},
+		xsdType: &xsd.ComplexType{
+			Name:      xml.Name{name.Space, w},
+			Anonymous: true,
+		},
+	}
+	return w
+}
+
+// markerInterface returns an interface type with the unexported method
+// marker, and a Validate method if Validate methods are generated. The
+// type is built rather than parsed, as nodes with positions would
+// cause the doc comment of the declaration that follows it to be
+// printed at the end of its line.
+func (cfg *Config) markerInterface(marker string) *ast.InterfaceType {
+	methods := []*ast.Field{{
+		Names: []*ast.Ident{ast.NewIdent(marker)},
+		Type:  &ast.FuncType{Params: &ast.FieldList{}},
+	}}
+	if cfg.validateMethods {
+		methods = append(methods, &ast.Field{
+			Names: []*ast.Ident{ast.NewIdent("Validate")},
+			Type: &ast.FuncType{
+				Params:  &ast.FieldList{},
+				Results: &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("error")}}},
+			},
+		})
+	}
+	return &ast.InterfaceType{Methods: &ast.FieldList{List: methods}}
+}
+
+// genSubstitutionGroup generates the interface of a substitution
+// group, and the helper types of its fields.
+func (cfg *Config) genSubstitutionGroup(g *substitutionGroup, members []substitute) ([]spec, error) {
+	var data struct {
+		Name, Helper, Head string
+		Members            []substitute
+		// The first member of each type, which is used to
+		// marshal values of that type. Members sharing a type
+		// with an earlier member have types of their own.
+		Marshal []substitute
+	}
+	data.Name = g.name
+	data.Helper = g.helper
+	data.Head = g.head.Local
+	data.Members = members
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range members {
+		names = append(names, m.Name.Local)
+		if !seen[m.Type] {
+			seen[m.Type] = true
+			data.Marshal = append(data.Marshal, m)
+		}
+	}
+
+	iface := spec{
+		doc: fmt.Sprintf("%s is implemented by the types of the elements that may appear\n"+
+			"in place of a %s element: %s.", g.name, g.head.Local, strings.Join(names, ", ")),
+		name: g.name,
+		expr: cfg.markerInterface("is" + g.name),
+		xsdType: &xsd.ComplexType{
+			Name:      xml.Name{g.head.Space, g.name},
+			Anonymous: true,
+		},
+	}
+	result := []spec{iface}
+
+	// The helper of a plural field uses the helper of a single
+	// field for each element.
+	unmarshal, err := gen.Func("UnmarshalXML").
+		Receiver("h "+data.Helper).
+		Args("d *xml.Decoder", "start xml.StartElement").
+		Returns("error").
+		BodyTmpl(`
+			switch {
+			{{- range .Members}}
+			case start.Name.Local == {{printf "%q" .Name.Local}}
+				{{- if .Name.Space}} && start.Name.Space == {{printf "%q" .Name.Space}}{{end}}:
+				var v {{.Type}}
+				if err := d.DecodeElement(&v, &start); err != nil {
+					return err
+				}
+				*h.v = v
+				return nil
+			{{- end}}
+			}
+			return d.Skip()
+		`, data).Decl()
+	if err != nil {
+		return nil, err
+	}
+	marshal, err := gen.Func("MarshalXML").
+		Receiver("h "+data.Helper).
+		Args("e *xml.Encoder", "start xml.StartElement").
+		Returns("error").
+		BodyTmpl(`
+			switch v := (*h.v).(type) {
+			case nil:
+				return nil
+			{{- range .Marshal}}
+			case {{.Type}}, *{{.Type}}:
+				start = xml.StartElement{Name: xml.Name{Space: {{printf "%q" .Name.Space}}, Local: {{printf "%q" .Name.Local}}}}
+				return e.EncodeElement(v, start)
+			{{- end}}
+			}
+			return fmt.Errorf("cannot marshal %T as a {{.Head}} element", *h.v)
+		`, data).Decl()
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, spec{
+		name:    g.helper,
+		expr:    gen.Struct(ast.NewIdent("v"), &ast.StarExpr{X: ast.NewIdent(g.name)}, nil),
+		private: true,
+		xsdType: &xsd.ComplexType{
+			Name:      xml.Name{g.head.Space, g.helper},
+			Anonymous: true,
+		},
+		methods: []*ast.FuncDecl{unmarshal, marshal},
+	})
+
+	if g.plural {
+		unmarshal, err = gen.Func("UnmarshalXML").
+			Receiver("h "+g.listHelper).
+			Args("d *xml.Decoder", "start xml.StartElement").
+			Returns("error").
+			Body(`
+				var v %[1]s
+				if err := (%[2]s{&v}).UnmarshalXML(d, start); err != nil {
+					return err
+				}
+				if v != nil {
+					*h.v = append(*h.v, v)
+				}
+				return nil
+			`, g.name, g.helper).Decl()
+		if err != nil {
+			return nil, err
+		}
+		marshal, err = gen.Func("MarshalXML").
+			Receiver("h "+g.listHelper).
+			Args("e *xml.Encoder", "start xml.StartElement").
+			Returns("error").
+			Body(`
+				for i := range *h.v {
+					if err := (%s{&(*h.v)[i]}).MarshalXML(e, start); err != nil {
+						return err
+					}
+				}
+				return nil
+			`, g.helper).Decl()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, spec{
+			name:    g.listHelper,
+			expr:    gen.Struct(ast.NewIdent("v"), &ast.StarExpr{X: &ast.ArrayType{Elt: ast.NewIdent(g.name)}}, nil),
+			private: true,
+			xsdType: &xsd.ComplexType{
+				Name:      xml.Name{g.head.Space, g.listHelper},
+				Anonymous: true,
+			},
+			methods: []*ast.FuncDecl{unmarshal, marshal},
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package xsdgen
+
+import (
+	"go/ast"
+	"go/parser"
+
+	"aqwari.net/xml/xsd"
+)
+
+// With the MapType option, values of an XML type are held by an
+// existing Go type, which is not declared in the generated code. The
+// Go type must unmarshal and marshal itself, such as by implementing
+// encoding.TextUnmarshaler and encoding.TextMarshaler.
+type mappedType struct {
+	// import path of the package declaring the Go type, if any
+	path string
+	// the Go type, such as civil.DateTime, and the name of the
+	// package it refers to
+	goType, name string
+}
+
+// Returns the Go type an XML type is mapped to, or false if it is
+// not mapped.
+func (cfg *Config) mappedType(t xsd.Type) (mappedType, bool) {
+	if t == nil || len(cfg.typeMap) == 0 {
+		return mappedType{}, false
+	}
+	m, ok := cfg.typeMap[xsd.XMLName(t)]
+	return m, ok
+}
+
+// Reports whether values of t are unmarshalled and marshalled through
+// a helper type. Built-in types that are mapped to other Go types are
+// not.
+func (cfg *Config) usesHelper(t xsd.Type) bool {
+	if _, ok := cfg.mappedType(t); ok {
+		return false
+	}
+	return nonTrivialBuiltin(t)
+}
+
+func newMappedType(importPath, goType string) (mappedType, error) {
+	expr, err := parser.ParseExpr(goType)
+	if err != nil {
+		return mappedType{}, err
+	}
+	m := mappedType{path: importPath, goType: goType}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && m.name == "" {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				m.name = x.Name
+			}
+		}
+		return m.name == ""
+	})
+	return m, nil
+}
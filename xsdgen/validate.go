@@ -0,0 +1,494 @@
+package xsdgen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+	"time"
+
+	"aqwari.net/xml/internal/gen"
+	"aqwari.net/xml/xsd"
+)
+
+// A fieldCheck describes a struct field that is checked by the
+// Validate method of a complex type.
+type fieldCheck struct {
+	// Name of the struct field
+	Field string
+	// Name of the element or attribute, used in error messages.
+	// Errors from fields without a label are returned as is.
+	Label, Kind string
+	// True if the field must be present
+	Required bool
+	// True if the field is a slice of elements
+	Plural bool
+	// A Go expression that is true when the field holds its zero
+	// value, and its negation. Empty if the field is always
+	// validated.
+	Empty, Present string
+	// True if the Go type of the field has a Validate method
+	Validate bool
+}
+
+// hasValidate returns true if the Go type generated for t has
+// a Validate method when using the ValidateMethods option.
+func (cfg *Config) hasValidate(t xsd.Type) bool {
+	if _, ok := cfg.mappedType(t); ok {
+		return false
+	}
+	switch t.(type) {
+	case *xsd.SimpleType, *xsd.ComplexType:
+		return true
+	}
+	return false
+}
+
+// emptyExpr returns a Go expression that is true when x, a value of
+// the Go type generated for t, holds its zero value. The second
+// return value is false when the zero value is also a meaningful
+// value, as is the case for numbers and booleans.
+func (cfg *Config) emptyExpr(t xsd.Type, x string) (string, bool) {
+	var goType string
+	if _, ok := cfg.mappedType(t); ok {
+		return fmt.Sprintf("reflect.ValueOf(%s).IsZero()", x), true
+	}
+	if cfg.isTimeFormatType(t) {
+		return x + ".Time.IsZero()", true
+	}
+	switch t := t.(type) {
+	case *xsd.ComplexType:
+		return fmt.Sprintf("reflect.ValueOf(%s).IsZero()", x), true
+	case *xsd.SimpleType:
+		if t.List {
+			return fmt.Sprintf("len(%s) == 0", x), true
+		}
+		if len(t.Union) > 0 {
+			return fmt.Sprintf("%s.Which() == \"\"", x), true
+		}
+		goType = cfg.exprString(t.Base)
+	case xsd.Builtin:
+		goType = cfg.exprString(t)
+	}
+	switch goType {
+	case "string", "XSDDecimal":
+		return fmt.Sprintf("%s == \"\"", x), true
+	case "time.Time":
+		return fmt.Sprintf("time.Time(%s).IsZero()", x), true
+	case "[]byte", "[]string":
+		return fmt.Sprintf("len(%s) == 0", x), true
+	case "bool":
+		return "!" + x, false
+	case "XSDDuration":
+		return fmt.Sprintf("reflect.ValueOf(%s).IsZero()", x), false
+	case "byte", "int", "int64", "uint", "uint64", "float32", "float64":
+		return x + " == 0", false
+	}
+	return fmt.Sprintf("reflect.ValueOf(%s).IsZero()", x), true
+}
+
+// negate returns the negation of a boolean expression returned
+// by emptyExpr.
+func negate(expr string) string {
+	switch {
+	case strings.Contains(expr, " == "):
+		return strings.Replace(expr, " == ", " != ", 1)
+	case strings.HasPrefix(expr, "!"):
+		return expr[1:]
+	}
+	return "!" + expr
+}
+
+// newFieldCheck describes the checks made for a struct field holding
+// an element or attribute of type t. It returns false if the field
+// does not need to be checked.
+func (cfg *Config) newFieldCheck(field, kind string, name xml.Name, t xsd.Type, optional, plural bool) (fieldCheck, bool) {
+	check := fieldCheck{
+		Field:    field,
+		Kind:     kind,
+		Label:    name.Local,
+		Plural:   plural,
+		Validate: cfg.hasValidate(t),
+	}
+	if plural {
+		check.Required = !optional
+		check.Empty = fmt.Sprintf("len(t.%s) == 0", field)
+	} else {
+		empty, meaningful := cfg.emptyExpr(t, "t."+field)
+		check.Required = !optional && meaningful
+		if optional || check.Required {
+			check.Empty, check.Present = empty, negate(empty)
+		}
+	}
+	return check, check.Required || check.Validate
+}
+
+// pointerFieldCheck returns the check of an optional field that is
+// declared as a pointer, which is validated when it is not nil.
+func (cfg *Config) pointerFieldCheck(field, kind string, name xml.Name, t xsd.Type) (fieldCheck, bool) {
+	check := fieldCheck{
+		Field:    field,
+		Kind:     kind,
+		Label:    name.Local,
+		Empty:    fmt.Sprintf("t.%s == nil", field),
+		Present:  fmt.Sprintf("t.%s != nil", field),
+		Validate: cfg.hasValidate(t),
+	}
+	return check, check.Validate
+}
+
+// genComplexValidate generates a Validate method for a complex type,
+// checking that required elements and attributes are present and
+// calling the Validate method of each field that has one.
+func (cfg *Config) genComplexValidate(typeName string, checks []fieldCheck) (*ast.FuncDecl, error) {
+	return gen.Func("Validate").
+		Receiver("t "+typeName).
+		Returns("error").
+		Comment("Validate returns an error if t does not contain the elements and attributes\nrequired by its schema, or if any of its values are invalid.").
+		BodyTmpl(`
+			{{- define "wrap" -}}
+			{{if .Label -}}
+			fmt.Errorf("{{if eq .Kind "attribute"}}attribute {{end}}{{.Label}}: %v", err)
+			{{- else -}}
+			err
+			{{- end}}
+			{{- end}}
+			{{- range .}}
+			{{if .Required -}}
+			if {{.Empty}} {
+				return errors.New("missing required {{.Kind}} {{.Label}}")
+			}
+			{{end -}}
+			{{if .Validate -}}
+			{{if .Plural -}}
+			for i := range t.{{.Field}} {
+				if err := t.{{.Field}}[i].Validate(); err != nil {
+					return fmt.Errorf("{{.Label}}[%d]: %v", i, err)
+				}
+			}
+			{{- else if and .Empty (not .Required) -}}
+			if {{.Present}} {
+				if err := t.{{.Field}}.Validate(); err != nil {
+					return {{template "wrap" .}}
+				}
+			}
+			{{- else -}}
+			if err := t.{{.Field}}.Validate(); err != nil {
+					return {{template "wrap" .}}
+			}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			return nil
+		`, checks).Decl()
+}
+
+// genChoiceValidate generates a Validate method for the type
+// generated for a <choice> group.
+func (cfg *Config) genChoiceValidate(choice *choiceGroup, parent string) (*ast.FuncDecl, error) {
+	return gen.Func("Validate").
+		Receiver("c "+choice.typeName).
+		Returns("error").
+		Comment("Validate returns an error if c is empty but must hold an\nelement, or if the element it holds is invalid.").
+		BodyTmpl(`
+			{{if .Required -}}
+			if c.name == "" {
+				return errors.New("{{.Parent}} must contain one of {{.Names}}")
+			}
+			{{end -}}
+			if v, ok := c.value.(interface{ Validate() error }); ok {
+				if err := v.Validate(); err != nil {
+					return fmt.Errorf("%s: %v", c.name, err)
+				}
+			}
+			return nil
+		`, struct {
+			Required      bool
+			Parent, Names string
+		}{choice.required, parent, choice.names()}).Decl()
+}
+
+// hasFacets returns true if r restricts the values of its base type
+// in a way that can be checked by a generated Validate method.
+func hasFacets(r xsd.Restriction) bool {
+	return len(r.Enum) > 0 || r.Pattern != nil ||
+		r.HasMin || r.HasMax ||
+		r.HasLength || r.HasMinLength || r.HasMaxLength ||
+		r.TotalDigits != 0 || r.Precision != 0 ||
+		!r.MinDate.IsZero() || !r.MaxDate.IsZero()
+}
+
+// addSimpleValidate attaches a Validate method to a simple type,
+// checking the facets of its restriction.
+func (cfg *Config) addSimpleValidate(s spec) (spec, error) {
+	t := s.xsdType.(*xsd.SimpleType)
+	r := t.Restriction
+	var data struct {
+		Type, Pattern, PatternText         string
+		Length, MinLength, MaxLength       int
+		HasLength, HasMinLength            bool
+		HasMaxLength                       bool
+		Min, Max                           string
+		MinExclusive, MaxExclusive         bool
+		TotalDigits, Precision             int
+		MinDate, MaxDate                   string
+		MinUnix, MaxUnix                   int64
+		MinNano, MaxNano                   int
+		Enum, Text, Bytes, Number, Decimal bool
+		Time, List                         bool
+		TimeValue                          string
+	}
+	data.Type = s.name
+	data.Length, data.MinLength, data.MaxLength = r.Length, r.MinLength, r.MaxLength
+	data.HasLength, data.HasMinLength, data.HasMaxLength = r.HasLength, r.HasMinLength, r.HasMaxLength
+
+	if t.List {
+		data.List = true
+	} else if len(t.Union) > 0 {
+		fn, err := gen.Func("Validate").
+			Receiver("u " + s.name).
+			Returns("error").
+			Comment("Validate returns an error if the value held by u is invalid.").
+			Body(`
+				if v, ok := u.value.(interface{ Validate() error }); ok {
+					return v.Validate()
+				}
+				return nil
+			`).Decl()
+		if err != nil {
+			return s, err
+		}
+		s.methods = append(s.methods, fn)
+		return s, nil
+	} else {
+		switch cfg.exprString(t.Base) {
+		case "string":
+			data.Text = true
+		case "[]byte":
+			data.Bytes = true
+		case "time.Time":
+			data.Time, data.TimeValue = true, "time.Time(v)"
+		case "byte", "int", "int64", "uint", "uint64", "float32", "float64":
+			data.Number = true
+		case "XSDDecimal":
+			data.Decimal = true
+		}
+		if cfg.isTimeFormatType(t) {
+			data.Time, data.TimeValue = true, "v.Time"
+		}
+		for _, m := range s.methods {
+			if m.Name.Name == "IsValid" {
+				data.Enum = true
+			}
+		}
+	}
+	if r.Pattern != nil && (data.Text || data.Decimal) {
+		data.Pattern = "_" + s.name + "Pattern"
+		data.PatternText = r.Pattern.String()
+		s.decls = append(s.decls, patternDecl(data.Pattern, data.PatternText))
+	}
+	if data.Number || data.Decimal {
+		if r.HasMin {
+			data.Min = strconv.FormatFloat(r.Min, 'g', -1, 64)
+			data.MinExclusive = r.MinExclusive
+		}
+		if r.HasMax {
+			data.Max = strconv.FormatFloat(r.Max, 'g', -1, 64)
+			data.MaxExclusive = r.MaxExclusive
+		}
+		data.TotalDigits, data.Precision = r.TotalDigits, r.Precision
+		if data.TotalDigits != 0 || data.Precision != 0 {
+			s.helperFuncs = append(s.helperFuncs, "_countDigits")
+		}
+	}
+	if data.Time {
+		if !r.MinDate.IsZero() {
+			data.MinExclusive = r.MinExclusive
+			data.MinDate = r.MinDate.Format(time.RFC3339Nano)
+			data.MinUnix, data.MinNano = r.MinDate.Unix(), r.MinDate.Nanosecond()
+		}
+		if !r.MaxDate.IsZero() {
+			data.MaxExclusive = r.MaxExclusive
+			data.MaxDate = r.MaxDate.Format(time.RFC3339Nano)
+			data.MaxUnix, data.MaxNano = r.MaxDate.Unix(), r.MaxDate.Nanosecond()
+		}
+	}
+	fn, err := gen.Func("Validate").
+		Receiver("v "+s.name).
+		Returns("error").
+		Comment("Validate returns an error if v does not satisfy the restrictions\nof its simple type.").
+		BodyTmpl(`
+			{{- if .Enum}}
+			if !v.IsValid() {
+				return fmt.Errorf("{{if .Text}}%q{{else}}%v{{end}} is not a valid {{.Type}}", v)
+			}
+			{{- end}}
+			{{- if .Pattern}}
+			if !{{.Pattern}}.MatchString(string(v)) {
+				return fmt.Errorf("%q does not match pattern %s", v, {{printf "%q" .PatternText}})
+			}
+			{{- end}}
+			{{- if and (or .Text .Bytes .List) (or .HasLength .HasMinLength .HasMaxLength)}}
+			{{if .Text -}}
+			n := utf8.RuneCountInString(string(v))
+			{{- else -}}
+			n := len(v)
+			{{- end}}
+			{{- if .HasLength}}
+			if n != {{.Length}} {
+				return fmt.Errorf("length of %q must be {{.Length}}", v)
+			}
+			{{- end}}
+			{{- if .HasMinLength}}
+			if n < {{.MinLength}} {
+				return fmt.Errorf("length of %q must be at least {{.MinLength}}", v)
+			}
+			{{- end}}
+			{{- if .HasMaxLength}}
+			if n > {{.MaxLength}} {
+				return fmt.Errorf("length of %q must be at most {{.MaxLength}}", v)
+			}
+			{{- end}}
+			{{- end}}
+			{{- if and .Decimal (or .Min .Max)}}
+			r, ok := XSDDecimal(v).Rat()
+			if !ok {
+				return fmt.Errorf("%q is not a valid decimal", v)
+			}
+			{{- if .Min}}
+			{{- if .MinExclusive}}
+			if r.Cmp(new(big.Rat).SetFloat64({{.Min}})) <= 0 {
+				return fmt.Errorf("%s is not greater than the exclusive minimum {{.Min}}", v)
+			}
+			{{- else}}
+			if r.Cmp(new(big.Rat).SetFloat64({{.Min}})) < 0 {
+				return fmt.Errorf("%s is less than the minimum {{.Min}}", v)
+			}
+			{{- end}}
+			{{- end}}
+			{{- if .Max}}
+			{{- if .MaxExclusive}}
+			if r.Cmp(new(big.Rat).SetFloat64({{.Max}})) >= 0 {
+				return fmt.Errorf("%s is not less than the exclusive maximum {{.Max}}", v)
+			}
+			{{- else}}
+			if r.Cmp(new(big.Rat).SetFloat64({{.Max}})) > 0 {
+				return fmt.Errorf("%s is greater than the maximum {{.Max}}", v)
+			}
+			{{- end}}
+			{{- end}}
+			{{- else}}
+			{{- if .Min}}
+			{{- if .MinExclusive}}
+			if float64(v) <= {{.Min}} {
+				return fmt.Errorf("%v is not greater than the exclusive minimum {{.Min}}", v)
+			}
+			{{- else}}
+			if float64(v) < {{.Min}} {
+				return fmt.Errorf("%v is less than the minimum {{.Min}}", v)
+			}
+			{{- end}}
+			{{- end}}
+			{{- if .Max}}
+			{{- if .MaxExclusive}}
+			if float64(v) >= {{.Max}} {
+				return fmt.Errorf("%v is not less than the exclusive maximum {{.Max}}", v)
+			}
+			{{- else}}
+			if float64(v) > {{.Max}} {
+				return fmt.Errorf("%v is greater than the maximum {{.Max}}", v)
+			}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			{{- if or .TotalDigits .Precision}}
+			{{if .TotalDigits}}intDigits{{else}}_{{end}}, fracDigits := _countDigits(
+				{{- if .Decimal}}string(v){{else}}strconv.FormatFloat(float64(v), 'f', -1, 64){{end}})
+			{{- if .TotalDigits}}
+			if intDigits+fracDigits > {{.TotalDigits}} {
+				return fmt.Errorf("%v has more than {{.TotalDigits}} digits", v)
+			}
+			{{- end}}
+			{{- if .Precision}}
+			if fracDigits > {{.Precision}} {
+				return fmt.Errorf("%v has more than {{.Precision}} fraction digits", v)
+			}
+			{{- end}}
+			{{- end}}
+			{{- if .MinDate}}
+			{{- if .MinExclusive}}
+			if !{{.TimeValue}}.After(time.Unix({{.MinUnix}}, {{.MinNano}})) {
+				return fmt.Errorf("%s is not after the exclusive minimum {{.MinDate}}", {{.TimeValue}}.Format(time.RFC3339Nano))
+			}
+			{{- else}}
+			if {{.TimeValue}}.Before(time.Unix({{.MinUnix}}, {{.MinNano}})) {
+				return fmt.Errorf("%s is before the minimum {{.MinDate}}", {{.TimeValue}}.Format(time.RFC3339Nano))
+			}
+			{{- end}}
+			{{- end}}
+			{{- if .MaxDate}}
+			{{- if .MaxExclusive}}
+			if !{{.TimeValue}}.Before(time.Unix({{.MaxUnix}}, {{.MaxNano}})) {
+				return fmt.Errorf("%s is not before the exclusive maximum {{.MaxDate}}", {{.TimeValue}}.Format(time.RFC3339Nano))
+			}
+			{{- else}}
+			if {{.TimeValue}}.After(time.Unix({{.MaxUnix}}, {{.MaxNano}})) {
+				return fmt.Errorf("%s is after the maximum {{.MaxDate}}", {{.TimeValue}}.Format(time.RFC3339Nano))
+			}
+			{{- end}}
+			{{- end}}
+			return nil
+		`, data).Decl()
+	if err != nil {
+		return s, fmt.Errorf("Validate %s: %v", s.name, err)
+	}
+	s.methods = append(s.methods, fn)
+	return s, nil
+}
+
+// isTimeFormatType returns true if values of t are held by one of
+// the helper types generated for the PreserveTimeFormat option.
+func (cfg *Config) isTimeFormatType(t xsd.Type) bool {
+	if _, ok := cfg.mappedType(t); ok {
+		return false
+	}
+	if s, ok := t.(*xsd.SimpleType); ok && !s.List && len(s.Union) == 0 {
+		t = s.Base
+	}
+	b, ok := t.(xsd.Builtin)
+	return ok && cfg.preserveTimeFormat && isTimeBuiltin(b)
+}
+
+// removeMethod returns methods without the method called name.
+func removeMethod(methods []*ast.FuncDecl, name string) []*ast.FuncDecl {
+	result := methods[:0]
+	for _, m := range methods {
+		if m.Name.Name != name {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// patternDecl declares a variable called name holding a compiled
+// regular expression that matches the whole of a value against an
+// XSD pattern.
+func patternDecl(name, pattern string) ast.Decl {
+	pattern = "^(?:" + pattern + ")$"
+	lit := &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(pattern)}
+	if !strings.Contains(pattern, "`") {
+		lit.Value = "`" + pattern + "`"
+	}
+	return &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Values: []ast.Expr{&ast.CallExpr{
+				Fun:  ast.NewIdent("regexp.MustCompile"),
+				Args: []ast.Expr{lit},
+			}},
+		}},
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"go/ast"
 	"go/token"
 	"io"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"aqwari.net/xml/internal/dependency"
 	"aqwari.net/xml/internal/gen"
@@ -77,6 +79,8 @@ type Code struct {
 	names map[xml.Name]string
 	decls specListing
 	types map[xml.Name]xsd.Type
+	// helper functions added to the methods of a type, by name
+	helpers map[string]*ast.FuncDecl
 }
 
 // DocType retrieves the complexType for the provided target
@@ -97,7 +101,11 @@ func (c *Code) NameOf(name xml.Name) string {
 	}
 
 	if b, err := xsd.ParseBuiltin(name); err == nil {
-		s, err := gen.ToString(builtinExpr(b))
+		expr, err := c.cfg.expr(b)
+		if err != nil {
+			return "ERROR" + name.Local
+		}
+		s, err := gen.ToString(expr)
 		if err != nil {
 			return "ERROR" + name.Local
 		}
@@ -135,9 +143,10 @@ func (cfg *Config) gen(primaries, deps []xsd.Schema) (*Code, error) {
 	var errList errorList
 
 	code := &Code{
-		cfg:   cfg,
-		names: make(map[xml.Name]string),
-		decls: make(specListing),
+		cfg:     cfg,
+		names:   make(map[xml.Name]string),
+		decls:   make(specListing),
+		helpers: make(map[string]*ast.FuncDecl),
 	}
 
 	all := make(map[xml.Name]xsd.Type)
@@ -153,6 +162,21 @@ func (cfg *Config) gen(primaries, deps []xsd.Schema) (*Code, error) {
 	}
 
 	code.types = all
+	schema := make([]xsd.Schema, 0, len(primaries)+len(deps))
+	schema = append(append(schema, primaries...), deps...)
+	if cfg.groupStructs {
+		cfg.addGroups(all, schema)
+	}
+	if cfg.substitutionGroups {
+		cfg.addSubstitutionGroups(all, schema)
+	}
+	if cfg.derivedTypes {
+		cfg.addDerivedTypes(all)
+	}
+	if cfg.nillableElements {
+		cfg.addNillableTypes(all)
+	}
+	nameUnionMembers(all)
 	if cfg.preprocessType != nil {
 		cfg.debugf("running user-defined pre-processing functions")
 		for i, primary := range primaries {
@@ -177,6 +201,19 @@ func (cfg *Config) gen(primaries, deps []xsd.Schema) (*Code, error) {
 		types := cfg.flatten(primary.Types)
 		types = cfg.expandComplexTypes(types)
 		for _, t := range types {
+			if m, ok := cfg.mappedType(t); ok {
+				code.names[xsd.XMLName(t)] = m.goType
+				continue
+			}
+			if imp, ok := cfg.imports[xsd.XMLName(t).Space]; ok {
+				if name, ok := imp.typeName(cfg, t); !ok {
+					errList = append(errList, fmt.Errorf("type %q is not declared by package %s",
+						xsd.XMLName(t).Local, imp.path))
+				} else {
+					code.names[xsd.XMLName(t)] = name
+				}
+				continue
+			}
 			specs, err := cfg.genTypeSpec(t)
 			if err != nil {
 				errList = append(errList, fmt.Errorf("gen type %q: %v",
@@ -194,6 +231,27 @@ func (cfg *Config) gen(primaries, deps []xsd.Schema) (*Code, error) {
 		return nil, errList
 	}
 
+	if cfg.substitutionGroups {
+		if err := cfg.addSubstitutionSpecs(code); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.derivedTypes {
+		if err := cfg.addDerivedSpecs(code); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.nillableElements {
+		if err := cfg.addNillableSpecs(code); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.rootElements {
+		if err := cfg.addRootElements(code, primaries); err != nil {
+			return nil, err
+		}
+	}
+
 	if cfg.postprocessType != nil {
 		cfg.debugf("running user-defined post-processing functions")
 		for name, s := range code.decls {
@@ -201,6 +259,19 @@ func (cfg *Config) gen(primaries, deps []xsd.Schema) (*Code, error) {
 		}
 	}
 
+	if cfg.arbitraryPrecision {
+		// The XSDDecimal type is not a helper of any particular
+		// type, so it is declared if anything refers to it.
+		h := cfg.helperTypes[xsd.XMLName(xsd.Decimal)]
+		for _, s := range code.decls {
+			if refersTo(s, h.name) {
+				code.decls[h.name] = h
+				delete(cfg.helperTypes, xsd.XMLName(xsd.Decimal))
+				break
+			}
+		}
+	}
+
 	for t, s := range code.decls {
 		cfg.debugf("processing dependencies for type %v", t)
 		for _, dep := range s.helperTypes {
@@ -217,6 +288,7 @@ func (cfg *Config) gen(primaries, deps []xsd.Schema) (*Code, error) {
 				cfg.debugf("adding helper function %v for type %v", dep, t)
 				s.methods = append(s.methods, h)
 				code.decls[t] = s
+				code.helpers[dep] = h
 				delete(cfg.helperFuncs, dep)
 			}
 		}
@@ -247,6 +319,7 @@ func (code *Code) GenAST() (*ast.File, error) {
 			},
 		}
 		file.Decls = append(file.Decls, typeDecl)
+		file.Decls = append(file.Decls, info.decls...)
 		for _, f := range info.methods {
 			file.Decls = append(file.Decls, f)
 		}
@@ -256,14 +329,33 @@ func (code *Code) GenAST() (*ast.File, error) {
 		pkgname = "ws"
 	}
 	file.Name = ast.NewIdent(pkgname)
+	code.cfg.addImports(token.NewFileSet(), &file)
 	return &file, nil
 }
 
+// refersTo returns true if the declaration or methods of a type
+// contain the identifier name.
+func refersTo(s spec, name string) bool {
+	found := false
+	visit := func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	}
+	ast.Inspect(s.expr, visit)
+	for _, m := range s.methods {
+		ast.Inspect(m, visit)
+	}
+	return found
+}
+
 type spec struct {
 	name, doc   string
 	expr        ast.Expr
 	private     bool
 	methods     []*ast.FuncDecl
+	decls       []ast.Decl // consts or vars following the type
 	xsdType     xsd.Type
 	helperTypes []xml.Name
 	helperFuncs []string
@@ -407,6 +499,10 @@ func (cfg *Config) flatten1(t xsd.Type, push func(xsd.Type), depth int) xsd.Type
 	if depth > maxDepth {
 		return t
 	}
+	if _, ok := cfg.mappedType(t); ok {
+		// The Go type it is mapped to is not declared.
+		return t
+	}
 	switch t := t.(type) {
 	case *xsd.SimpleType:
 		var (
@@ -416,6 +512,11 @@ func (cfg *Config) flatten1(t xsd.Type, push func(xsd.Type), depth int) xsd.Type
 		)
 		// TODO: handle list/union types
 		for base = xsd.Base(t); base != nil; base = xsd.Base(base) {
+			if _, ok := cfg.mappedType(base); ok {
+				// Types derived from a mapped type are
+				// held by the same Go type.
+				return base
+			}
 			if builtin, ok = base.(xsd.Builtin); ok {
 				break
 			}
@@ -424,7 +525,11 @@ func (cfg *Config) flatten1(t xsd.Type, push func(xsd.Type), depth int) xsd.Type
 		for _, v := range chain {
 			if v, ok := v.(*xsd.SimpleType); ok {
 				v.Base = builtin
-				push(v)
+				// Anonymous bases, such as the original
+				// of a redefined type, are not declared.
+				if !v.Anonymous {
+					push(v)
+				}
 			}
 		}
 		t.Base = builtin
@@ -441,10 +546,19 @@ func (cfg *Config) flatten1(t xsd.Type, push func(xsd.Type), depth int) xsd.Type
 		// is useful enough for its own Go type. Our threshold for "useful enough"
 		// is pretty low; if we can attach a godoc comment to it describing how it
 		// should be used, that's good enough.
+		for i, member := range t.Union {
+			t.Union[i] = cfg.flatten1(member, push, depth+1)
+			push(t.Union[i])
+		}
 		if t.List || len(t.Union) > 0 {
 			return t
 		}
-		if nonTrivialBuiltin(t.Base) {
+		if b, ok := t.Base.(xsd.Builtin); ok && cfg.arbitraryPrecision && b != xsd.Decimal && bigNumberExpr(b) != nil {
+			// *big.Int cannot be the underlying type of a
+			// type with methods.
+			return t.Base
+		}
+		if cfg.usesHelper(t.Base) {
 			return t
 		}
 		if len(t.Restriction.Enum) > 0 {
@@ -463,6 +577,9 @@ func (cfg *Config) flatten1(t xsd.Type, push func(xsd.Type), depth int) xsd.Type
 			t.Doc = "Must be at least " + strconv.Itoa(t.Restriction.MinLength) + " items long"
 			return t
 		}
+		if cfg.validateMethods && hasFacets(t.Restriction) {
+			return t
+		}
 		return t.Base
 	case *xsd.ComplexType:
 		// We can "unpack" a struct if it is extending a simple
@@ -484,6 +601,11 @@ func (cfg *Config) flatten1(t xsd.Type, push func(xsd.Type), depth int) xsd.Type
 				}
 			}
 		}
+		if _, ok := cfg.imports[t.Name.Space]; ok {
+			// The fields of an imported type are declared
+			// by its package.
+			return t
+		}
 		// We can flatten a struct field if its type does not
 		// need additional methods for unmarshalling.
 		for i, el := range t.Elements {
@@ -539,6 +661,15 @@ func (cfg *Config) genTypeSpec(t xsd.Type) (result []spec, err error) {
 	if err != nil || s == nil {
 		return result, err
 	}
+	if cfg.validateMethods {
+		for i := range s {
+			if _, ok := s[i].xsdType.(*xsd.SimpleType); ok {
+				if s[i], err = cfg.addSimpleValidate(s[i]); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
 	return append(result, s...), nil
 }
 
@@ -548,6 +679,20 @@ type fieldOverride struct {
 	DefaultValue     string
 	Type             xsd.Type
 	Tag              string
+	// If true, ToType is a struct holding a pointer to the
+	// field, rather than a conversion of the field's type.
+	Wrap bool
+	// If not empty, the field is always marshalled with this
+	// value, and is not overridden when unmarshalling.
+	Fixed string
+}
+
+// A fieldInit sets a field to the default or fixed value of its
+// element or attribute, in the constructor of a complex type.
+type fieldInit struct {
+	Field, Value string
+	// If not empty, the field is a pointer to this type.
+	Pointer string
 }
 
 type nameGenerator struct {
@@ -590,10 +735,19 @@ func (gen *nameGenerator) element(base xml.Name) ast.Expr {
 }
 
 func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
+	return cfg.genStruct(t, nil)
+}
+
+// genStruct generates the struct type for a complex type. If group
+// is not nil, the struct is the type of a group's members, which has
+// no methods of its own; the names of its fields are recorded in group.
+func (cfg *Config) genStruct(t *xsd.ComplexType, group *groupStruct) ([]spec, error) {
 	var result []spec
 	var fields []ast.Expr
 	var overrides []fieldOverride
 	var helperTypes []xml.Name
+	var checks []fieldCheck
+	var inits []fieldInit
 
 	namegen := nameGenerator{cfg, make(map[string]struct{})}
 
@@ -618,6 +772,10 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 			cfg.debugf("complexType %[1]s extends simpleType %[2]s. Naming"+
 				" the chardata struct field after %[2]s", t.Name.Local, b.Name.Local)
 			fields = append(fields, expr, expr, gen.String(`xml:",chardata"`))
+			checks = append(checks, fieldCheck{
+				Field:    expr.(*ast.Ident).Name,
+				Validate: true,
+			})
 		case xsd.Builtin:
 			if b == xsd.AnyType {
 				// extending anyType doesn't really make sense, but
@@ -631,7 +789,7 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 				t.Name.Local, b)
 			name := "Value"
 			tag := `xml:",chardata"`
-			if nonTrivialBuiltin(b) {
+			if cfg.usesHelper(b) {
 				h, ok := cfg.helperTypes[xsd.XMLName(b)]
 				if !ok {
 					return nil, fmt.Errorf("missing helper type for %v", b)
@@ -645,6 +803,11 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 					Type:      b,
 				})
 			}
+			if star, ok := expr.(*ast.StarExpr); ok {
+				// encoding/xml does not allocate a nil pointer
+				// before unmarshalling character data into it.
+				expr = star.X
+			}
 			fields = append(fields, namegen.unique(name), expr, gen.String(tag))
 		default:
 			panic(fmt.Errorf("%s does not derive from a builtin type", t.Name.Local))
@@ -679,7 +842,66 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 	cfg.debugf("complexType %s: generating struct fields for %d elements and %d attributes",
 		xsd.XMLName(t).Local, len(elements), len(attributes))
 
+	var choice *choiceGroup
+	if cfg.typeSafeChoices && group == nil {
+		choice = cfg.findChoice(t, elements)
+	}
+
+	var embedded map[groupKey]*groupStruct
+	if cfg.groupStructs {
+		var specs []spec
+		var err error
+		embedded, specs, err = cfg.embeddedGroups(elements, attributes, choice)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", t.Name.Local, err)
+		}
+		result = append(result, specs...)
+		for _, g := range embedded {
+			g.reserve(&namegen)
+		}
+	}
+	// Only one field of a struct can hold elements of any name,
+	// so only the first reference to the head of a substitution
+	// group is declared with its interface type.
+	anyField := choice != nil
 	for _, el := range elements {
+		anyField = anyField || el.Wildcard
+	}
+
+	// The struct of a group is embedded at the position of
+	// its first member. Returns the name of the member's field
+	// in the group struct.
+	declared := make(map[groupKey]bool)
+	embed := func(key groupKey, member xml.Name) (string, bool) {
+		g, ok := embedded[key]
+		if !ok {
+			return "", false
+		}
+		if !declared[key] {
+			declared[key] = true
+			fields = append(fields, nil, ast.NewIdent(g.name), nil)
+		}
+		if key.attr {
+			return g.attributes[member], true
+		}
+		return g.elements[member], true
+	}
+
+	for _, el := range elements {
+		if choice != nil && choice.contains(el.Name) {
+			// All alternatives of the choice share a single field,
+			// at the position of the first alternative.
+			if choice.field == "" {
+				choice.field = namegen.unique("Choice").(*ast.Ident).Name
+				fields = append(fields, ast.NewIdent(choice.field),
+					ast.NewIdent(choice.typeName), gen.String(`xml:",any"`))
+				checks = append(checks, fieldCheck{
+					Field:    choice.field,
+					Validate: true,
+				})
+			}
+			continue
+		}
 		options := ""
 		if el.Nillable || el.Optional {
 			options = ",omitempty"
@@ -689,12 +911,62 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 		if err != nil {
 			return nil, fmt.Errorf("%s element %s: %v", t.Name.Local, el.Name.Local, err)
 		}
-		name := namegen.element(el.Name)
+		var name ast.Expr
+		fieldName, inGroup := embed(groupKey{el.Group, false}, el.Name)
+		if inGroup {
+			name = ast.NewIdent(fieldName)
+		} else {
+			name = namegen.element(el.Name)
+		}
+		var subst *substitutionGroup
+		if cfg.substitutionGroups && group == nil && !inGroup {
+			subst = cfg.substitutionGroupOf(el)
+		}
+		if subst != nil && anyField {
+			cfg.debugf("complexType %s: cannot hold substitutes for element %s",
+				t.Name.Local, el.Name.Local)
+			subst = nil
+		} else if subst != nil {
+			anyField = true
+			tag = `xml:",any"`
+			base = ast.NewIdent(subst.name)
+		}
+		var poly *derivedTypes
+		if cfg.derivedTypes && subst == nil {
+			poly = cfg.derivedTypesOf(el)
+		}
+		if poly != nil {
+			base = ast.NewIdent(poly.name)
+		}
+		var nillable *nillableType
+		if cfg.nillableElements && subst == nil && poly == nil {
+			nillable = cfg.nillableTypeOf(el)
+		}
+		if nillable != nil {
+			base = ast.NewIdent(nillable.name)
+			if el.Optional && !el.Plural {
+				base = &ast.StarExpr{X: base}
+			} else if !el.Optional {
+				// A nil element is marshalled by its wrapper.
+				tag = fmt.Sprintf(`xml:"%s %s"`, el.Name.Space, el.Name.Local)
+			}
+		}
+		var pointer bool
+		if cfg.optionalPointers && nillable == nil && subst == nil && poly == nil &&
+			el.Optional && !el.Plural && !el.Wildcard {
+			pointer = cfg.pointerField(el.Type, base)
+		}
+		if pointer {
+			base = &ast.StarExpr{X: base}
+		}
 		if el.Wildcard {
 			tag = `xml:",any"`
-			if el.Plural {
+			switch {
+			case inGroup:
+				// The group struct names the field.
+			case el.Plural:
 				name = ast.NewIdent("Items")
-			} else {
+			default:
 				name = ast.NewIdent("Item")
 			}
 			if b, ok := el.Type.(xsd.Builtin); ok && b == xsd.AnyType {
@@ -705,10 +977,56 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 		if el.Plural {
 			base = &ast.ArrayType{Elt: base}
 		}
-		fields = append(fields, name, base, gen.String(tag))
-		if el.Default != "" || nonTrivialBuiltin(el.Type) {
+		if group != nil {
+			group.elements[el.Name] = name.(*ast.Ident).Name
+		}
+		if !inGroup {
+			fields = append(fields, name, base, gen.String(tag))
+		}
+		if subst != nil {
+			overrides = append(overrides, subst.override(name.(*ast.Ident).Name, tag, el.Plural))
+			checks = append(checks, interfaceFieldCheck(name.(*ast.Ident).Name, el))
+			continue
+		}
+		if poly != nil {
+			if group == nil {
+				overrides = append(overrides, poly.override(name.(*ast.Ident).Name, tag, el.Plural))
+			}
+			checks = append(checks, interfaceFieldCheck(name.(*ast.Ident).Name, el))
+			continue
+		}
+		if nillable != nil {
+			// The wrapper has its own marshal methods.
+			if check, ok := nillable.fieldCheck(name.(*ast.Ident).Name, el); ok {
+				checks = append(checks, check)
+			}
+			continue
+		}
+		if value := el.Fixed; (value != "" || el.Default != "") && !el.Plural && !el.Wildcard {
+			if value == "" {
+				value = el.Default
+			}
+			if init, ok := cfg.fieldInit(name.(*ast.Ident).Name, el.Type, value, pointer); ok {
+				inits = append(inits, init)
+			}
+		}
+		if pointer {
+			if check, ok := cfg.pointerFieldCheck(name.(*ast.Ident).Name, "element", el.Name, el.Type); ok {
+				checks = append(checks, check)
+			}
+			if cfg.usesHelper(el.Type) {
+				helperTypes = append(helperTypes, xsd.XMLName(el.Type))
+			}
+			// The field type has its own marshal methods.
+			continue
+		}
+		if check, ok := cfg.newFieldCheck(name.(*ast.Ident).Name, "element", el.Name,
+			el.Type, el.Optional || el.Nillable || el.Wildcard, el.Plural); ok {
+			checks = append(checks, check)
+		}
+		if el.Default != "" || cfg.usesHelper(el.Type) {
 			typeName := cfg.exprString(el.Type)
-			if nonTrivialBuiltin(el.Type) {
+			if cfg.usesHelper(el.Type) {
 				h, ok := cfg.helperTypes[xsd.XMLName(el.Type)]
 				if !ok {
 					return nil, fmt.Errorf("no helper type for type %v element %v", t.Name, el.Name)
@@ -716,6 +1034,10 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 				helperTypes = append(helperTypes, xsd.XMLName(h.xsdType))
 				typeName = h.name
 			}
+			if el.Default == "" && typeName == cfg.exprString(el.Type) {
+				// The field type has its own marshal methods.
+				continue
+			}
 			overrides = append(overrides, fieldOverride{
 				DefaultValue: el.Default,
 				FieldName:    name.(*ast.Ident).Name,
@@ -747,12 +1069,54 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 		if err != nil {
 			return nil, fmt.Errorf("%s attribute %s: %v", t.Name.Local, attr.Name.Local, err)
 		}
+		pointer := cfg.optionalPointers && attr.Optional && !attr.Plural &&
+			cfg.pointerField(attr.Type, base)
+		if pointer {
+			base = &ast.StarExpr{X: base}
+		}
 		cfg.debugf("adding %s attribute %s as %v", t.Name.Local, attr.Name.Local, base)
-		name := namegen.attribute(attr.Name)
-		fields = append(fields, name, base, gen.String(tag))
-		if attr.Default != "" || nonTrivialBuiltin(attr.Type) {
+		var name ast.Expr
+		if fieldName, ok := embed(groupKey{attr.Group, true}, attr.Name); ok {
+			name = ast.NewIdent(fieldName)
+		} else {
+			name = namegen.attribute(attr.Name)
+			fields = append(fields, name, base, gen.String(tag))
+		}
+		if group != nil {
+			group.attributes[attr.Name] = name.(*ast.Ident).Name
+		}
+		if value := attr.Fixed; value != "" || attr.Default != "" {
+			if value == "" {
+				value = attr.Default
+			}
+			if init, ok := cfg.fieldInit(name.(*ast.Ident).Name, attr.Type, value, pointer); ok {
+				inits = append(inits, init)
+			}
+		}
+		if attr.Fixed != "" {
+			overrides = append(overrides, fieldOverride{
+				FieldName: name.(*ast.Ident).Name,
+				Fixed:     attr.Fixed,
+				Tag:       strings.Replace(tag, ",omitempty", "", 1),
+			})
+		}
+		if pointer {
+			if check, ok := cfg.pointerFieldCheck(name.(*ast.Ident).Name, "attribute", attr.Name, attr.Type); ok {
+				checks = append(checks, check)
+			}
+			if cfg.usesHelper(attr.Type) {
+				helperTypes = append(helperTypes, xsd.XMLName(attr.Type))
+			}
+			// The field type has its own marshal methods.
+			continue
+		}
+		if check, ok := cfg.newFieldCheck(name.(*ast.Ident).Name, "attribute", attr.Name,
+			attr.Type, attr.Optional, false); ok {
+			checks = append(checks, check)
+		}
+		if attr.Default != "" || cfg.usesHelper(attr.Type) {
 			typeName := cfg.exprString(attr.Type)
-			if nonTrivialBuiltin(attr.Type) {
+			if cfg.usesHelper(attr.Type) {
 				h, ok := cfg.helperTypes[xsd.XMLName(attr.Type)]
 				if !ok {
 					return nil, fmt.Errorf("no helper type for type %v attribute %v", t.Name, attr.Name)
@@ -760,6 +1124,10 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 				typeName = h.name
 				helperTypes = append(helperTypes, xsd.XMLName(attr.Type))
 			}
+			if attr.Default == "" && typeName == cfg.exprString(attr.Type) {
+				// The field type has its own marshal methods.
+				continue
+			}
 			overrides = append(overrides, fieldOverride{
 				DefaultValue: attr.Default,
 				FieldName:    name.(*ast.Ident).Name,
@@ -770,6 +1138,20 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 			})
 		}
 	}
+	if choice != nil {
+		cs, err := cfg.genChoiceType(t, choice)
+		if err != nil {
+			return nil, fmt.Errorf("%s choice: %v", t.Name.Local, err)
+		}
+		if cfg.validateMethods {
+			validate, err := cfg.genChoiceValidate(choice, cfg.public(t.Name))
+			if err != nil {
+				return nil, fmt.Errorf("%s choice: %v", t.Name.Local, err)
+			}
+			cs.methods = append(cs.methods, validate)
+		}
+		result = append(result, cs)
+	}
 	expr := gen.Struct(fields...)
 	s := spec{
 		doc:         t.Doc,
@@ -778,8 +1160,43 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 		xsdType:     t,
 		helperTypes: helperTypes,
 	}
-	if len(overrides) > 0 {
-		unmarshal, marshal, err := cfg.genComplexTypeMethods(t, overrides)
+	if group != nil {
+		// The types that embed the group unmarshal, marshal
+		// and validate its fields.
+		return append(result, s), nil
+	}
+	if len(inits) > 0 {
+		fn, err := gen.Func("New"+s.name).
+			Returns("*"+s.name).
+			Comment(fmt.Sprintf("New%s returns a new %s, with the default and fixed values\n"+
+				"of its elements and attributes.", s.name, s.name)).
+			BodyTmpl(`
+				t := new({{.Name}})
+				{{- range .Inits}}
+				{{if .Pointer -}}
+				t.{{.Field}} = new({{.Pointer}})
+				*t.{{.Field}} = {{.Value}}
+				{{- else -}}
+				t.{{.Field}} = {{.Value}}
+				{{- end}}
+				{{- end}}
+				return t
+			`, struct {
+				Name  string
+				Inits []fieldInit
+			}{s.name, inits}).Decl()
+		if err != nil {
+			return nil, fmt.Errorf("%s constructor: %v", t.Name.Local, err)
+		}
+		s.methods = append(s.methods, fn)
+	}
+	if choice == nil || !choice.required {
+		// Only required choices need to be checked when
+		// decoding the parent type.
+		choice = nil
+	}
+	if len(overrides) > 0 || choice != nil {
+		unmarshal, marshal, err := cfg.genComplexTypeMethods(t, overrides, choice)
 		if err != nil {
 			return result, err
 		} else {
@@ -791,50 +1208,201 @@ func (cfg *Config) genComplexType(t *xsd.ComplexType) ([]spec, error) {
 			}
 		}
 	}
+	if cfg.validateMethods {
+		validate, err := cfg.genComplexValidate(s.name, checks)
+		if err != nil {
+			return result, fmt.Errorf("%s Validate: %v", t.Name.Local, err)
+		}
+		s.methods = append(s.methods, validate)
+	}
 	result = append(result, s)
 	return result, nil
 }
 
-func (cfg *Config) genComplexTypeMethods(t *xsd.ComplexType, overrides []fieldOverride) (marshal, unmarshal *ast.FuncDecl, err error) {
+// Reports whether an optional element or attribute of type t is
+// declared as a pointer with the OptionalPointers option. Only simple
+// types are, except those declared as slices, which can be nil
+// already, and those marshalled by a helper type, whose zero value
+// is not marshalled.
+func (cfg *Config) pointerField(t xsd.Type, base ast.Expr) bool {
+	switch t := t.(type) {
+	case xsd.Builtin:
+		if t == xsd.AnyType {
+			return false
+		}
+	case *xsd.SimpleType:
+		if t.List {
+			return false
+		}
+	default:
+		return false
+	}
+	if _, ok := base.(*ast.ArrayType); ok {
+		return false
+	}
+	if cfg.usesHelper(t) {
+		h, ok := cfg.helperTypes[xsd.XMLName(t)]
+		return ok && h.name == cfg.exprString(t)
+	}
+	return true
+}
+
+// Returns the statement of a constructor that sets a field of type
+// t to a default or fixed value, if the value can be written as a
+// Go constant.
+func (cfg *Config) fieldInit(field string, t xsd.Type, value string, pointer bool) (fieldInit, bool) {
+	init := fieldInit{Field: field}
+	var ok bool
+	if init.Value, ok = cfg.constant(t, value); !ok {
+		cfg.debugf("cannot set %s to %q in constructor", field, value)
+		return init, false
+	}
+	if pointer {
+		init.Pointer = cfg.exprString(t)
+	} else if init.Value == "false" || init.Value == "0" || init.Value == `""` {
+		// The field has this value already.
+		return init, false
+	}
+	return init, true
+}
+
+// Returns a Go constant for a value of type t, if t is declared as a
+// string, boolean or number.
+func (cfg *Config) constant(t xsd.Type, value string) (string, bool) {
+	for {
+		if _, ok := cfg.mappedType(t); ok {
+			return "", false
+		}
+		st, ok := t.(*xsd.SimpleType)
+		if !ok {
+			break
+		}
+		if st.List || len(st.Union) > 0 {
+			return "", false
+		}
+		t = st.Base
+	}
+	b, ok := t.(xsd.Builtin)
+	if !ok {
+		return "", false
+	}
+	v := strings.TrimSpace(value)
+	switch cfg.exprString(b) {
+	case "string":
+		return strconv.Quote(value), true
+	case "bool":
+		switch v {
+		case "true", "1":
+			return "true", true
+		case "false", "0":
+			return "false", true
+		}
+	case "int", "int64":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return strconv.FormatInt(n, 10), true
+		}
+	case "byte", "uint", "uint64":
+		if n, err := strconv.ParseUint(strings.TrimPrefix(v, "+"), 10, 64); err == nil {
+			return strconv.FormatUint(n, 10), true
+		}
+	case "float32", "float64":
+		if f, err := strconv.ParseFloat(v, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return strconv.FormatFloat(f, 'g', -1, 64), true
+		}
+	}
+	return "", false
+}
+
+// Returns the check of a field declared with an interface type,
+// whose values validate themselves.
+func interfaceFieldCheck(field string, el xsd.Element) fieldCheck {
+	check := fieldCheck{
+		Field:    field,
+		Kind:     "element",
+		Label:    el.Name.Local,
+		Required: !el.Optional && !el.Nillable,
+		Plural:   el.Plural,
+		Validate: true,
+	}
+	if el.Plural {
+		check.Empty = fmt.Sprintf("len(t.%s) == 0", field)
+	} else {
+		check.Empty = fmt.Sprintf("t.%s == nil", field)
+		check.Present = fmt.Sprintf("t.%s != nil", field)
+	}
+	return check
+}
+
+func (cfg *Config) genComplexTypeMethods(t *xsd.ComplexType, overrides []fieldOverride, choice *choiceGroup) (marshal, unmarshal *ast.FuncDecl, err error) {
 	var data struct {
-		Overrides []fieldOverride
-		Type      string
+		Overrides   []fieldOverride
+		Type        string
+		Choice      string
+		ChoiceNames string
 	}
-	data.Overrides = overrides
 	data.Type = cfg.public(t.Name)
-
-	unmarshal, err = gen.Func("UnmarshalXML").
-		Receiver("t *"+data.Type).
-		Args("d *xml.Decoder", "start xml.StartElement").
-		Returns("error").
-		BodyTmpl(`
-			type T {{.Type}}
-			var overlay struct{
-				*T
+	if choice != nil {
+		data.Choice = choice.field
+		data.ChoiceNames = choice.names()
+	}
+	fixed := make(map[string]bool)
+	for _, v := range overrides {
+		if v.Fixed != "" {
+			fixed[v.FieldName] = true
+		} else {
+			data.Overrides = append(data.Overrides, v)
+		}
+	}
+	// Fixed values are only overridden when marshalling.
+	if len(data.Overrides) > 0 || choice != nil {
+		unmarshal, err = gen.Func("UnmarshalXML").
+			Receiver("t *"+data.Type).
+			Args("d *xml.Decoder", "start xml.StartElement").
+			Returns("error").
+			BodyTmpl(`
+				type T {{.Type}}
+				var overlay struct{
+					*T
+					{{range .Overrides}}
+					{{.FieldName}} *{{.ToType}} `+"`{{.Tag}}`"+`
+					{{end}}
+				}
+				overlay.T = (*T)(t)
 				{{range .Overrides}}
-				{{.FieldName}} *{{.ToType}} `+"`{{.Tag}}`"+`
+				{{if .Wrap -}}
+				overlay.{{.FieldName}} = &{{.ToType}}{&overlay.T.{{.FieldName}}}
+				{{- else -}}
+				overlay.{{.FieldName}} = (*{{.ToType}})(&overlay.T.{{.FieldName}})
+				{{- end}}
+				{{if .DefaultValue -}}
+				// overlay.{{.FieldName}} = {{.DefaultValue}}
+				{{end -}}
 				{{end}}
-			}
-			overlay.T = (*T)(t)
-			{{range .Overrides}}
-			overlay.{{.FieldName}} = (*{{.ToType}})(&overlay.T.{{.FieldName}})
-			{{if .DefaultValue -}}
-			// overlay.{{.FieldName}} = {{.DefaultValue}}
-			{{end -}}
-			{{end}}
 
-			return d.DecodeElement(&overlay, &start)
-		`, data).Decl()
-	if err != nil {
-		return nil, nil, err
+				{{if .Choice -}}
+				if err := d.DecodeElement(&overlay, &start); err != nil {
+					return err
+				}
+				if overlay.T.{{.Choice}}.Which() == "" {
+					return errors.New("{{.Type}} must contain one of {{.ChoiceNames}}")
+				}
+				return nil
+				{{- else -}}
+				return d.DecodeElement(&overlay, &start)
+				{{- end}}
+			`, data).Decl()
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// We don't set defaults in MarshalXML; there's no way to distinguish
 	// an intentional zero value from "no value", and the consumer of the
-	// XML should know what the default is from the XSD.
+	// XML should know what the default is from the XSD. Fixed values
+	// are always set.
 	nonDefaultOverrides := make([]fieldOverride, 0, len(overrides))
 	for _, v := range overrides {
-		if nonTrivialBuiltin(v.Type) {
+		if v.Fixed != "" || (cfg.usesHelper(v.Type) || v.Wrap) && !fixed[v.FieldName] {
 			nonDefaultOverrides = append(nonDefaultOverrides, v)
 		}
 	}
@@ -852,12 +1420,22 @@ func (cfg *Config) genComplexTypeMethods(t *xsd.ComplexType, overrides []fieldOv
 			var layout struct{
 				*T
 				{{- range .Overrides}}
+				{{if .Fixed -}}
+				{{.FieldName}} string`+"`{{.Tag}}`"+`
+				{{- else -}}
 				{{.FieldName}} *{{.ToType}}`+"`{{.Tag}}`"+`
+				{{- end}}
 				{{end -}}
 			}
 			layout.T = (*T)(t)
 			{{- range .Overrides}}
+			{{if .Fixed -}}
+			layout.{{.FieldName}} = {{printf "%q" .Fixed}}
+			{{- else if .Wrap -}}
+			layout.{{.FieldName}} = &{{.ToType}}{&layout.T.{{.FieldName}}}
+			{{- else -}}
 			layout.{{.FieldName}} = (*{{.ToType}})(&layout.T.{{.FieldName}})
+			{{- end}}
 			{{end -}}
 
 			return e.EncodeElement(layout, start)
@@ -865,23 +1443,287 @@ func (cfg *Config) genComplexTypeMethods(t *xsd.ComplexType, overrides []fieldOv
 	return marshal, unmarshal, err
 }
 
+// A choiceGroup is a <choice> whose alternatives are generated as a
+// single struct field, when using the TypeSafeChoices option.
+type choiceGroup struct {
+	// name of the generated type, and of the field in its parent
+	typeName, field string
+	// true if one of the alternatives must be present
+	required bool
+	elements []xsd.Element
+}
+
+func (c *choiceGroup) contains(name xml.Name) bool {
+	for _, el := range c.elements {
+		if el.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *choiceGroup) names() string {
+	names := make([]string, 0, len(c.elements))
+	for _, el := range c.elements {
+		names = append(names, el.Name.Local)
+	}
+	return strings.Join(names, ", ")
+}
+
+// findChoice returns the first <choice> group in the content of
+// a complex type that can be generated as a separate type.
+func (cfg *Config) findChoice(t *xsd.ComplexType, elements []xsd.Element) *choiceGroup {
+	if t.Content == nil {
+		return nil
+	}
+	for _, el := range elements {
+		if el.Wildcard {
+			cfg.debugf("complexType %s: contains a wildcard, not generating choice type",
+				t.Name.Local)
+			return nil
+		}
+	}
+	candidates := []xsd.Particle{*t.Content}
+	if t.Content.Kind == xsd.SequenceParticle && !t.Content.Plural() {
+		candidates = t.Content.Particles
+	}
+Loop:
+	for _, p := range candidates {
+		if p.Kind != xsd.ChoiceParticle {
+			continue
+		}
+		if p.Plural() {
+			cfg.debugf("complexType %s: choice may occur more than once, not generating choice type",
+				t.Name.Local)
+			continue
+		}
+		choice := &choiceGroup{
+			typeName: cfg.public(t.Name) + "Choice",
+			required: !p.Optional(),
+		}
+		for _, alt := range p.Particles {
+			if alt.Kind != xsd.ElementParticle {
+				cfg.debugf("complexType %s: choice contains a model group, not generating choice type",
+					t.Name.Local)
+				continue Loop
+			}
+			found := false
+			for _, el := range elements {
+				if el.Name == alt.Name {
+					choice.elements = append(choice.elements, el)
+					found = true
+					break
+				}
+			}
+			if !found {
+				cfg.debugf("complexType %s: choice alternative %s is filtered, not generating choice type",
+					t.Name.Local, alt.Name.Local)
+				continue Loop
+			}
+		}
+		if len(choice.elements) > 0 {
+			return choice
+		}
+	}
+	return nil
+}
+
+type choiceAlternative struct {
+	Name xml.Name
+	// Names of the getter and setter methods
+	Get, Set string
+	// Go type of a single element, and the helper type used to
+	// marshal it, if any.
+	Item, Helper string
+	// Go type of the value held by the choice type
+	Type   string
+	Plural bool
+}
+
+// genChoiceType generates a type holding exactly one of the
+// alternatives of a <choice> group.
+func (cfg *Config) genChoiceType(t *xsd.ComplexType, choice *choiceGroup) (spec, error) {
+	var data struct {
+		Type, Parent string
+		Names        string
+		Required     bool
+		Alternatives []choiceAlternative
+	}
+	data.Type = choice.typeName
+	data.Parent = cfg.public(t.Name)
+	data.Names = choice.names()
+	data.Required = choice.required
+
+	var helperTypes []xml.Name
+	methods := nameGenerator{cfg, map[string]struct{}{
+		"Which":        struct{}{},
+		"MarshalXML":   struct{}{},
+		"UnmarshalXML": struct{}{},
+	}}
+	for _, el := range choice.elements {
+		item := cfg.exprString(el.Type)
+		if item == "" {
+			return spec{}, fmt.Errorf("element %s: unknown type %s",
+				el.Name.Local, xsd.XMLName(el.Type).Local)
+		}
+		alt := choiceAlternative{
+			Name:   el.Name,
+			Get:    methods.unique(cfg.public(el.Name)).(*ast.Ident).Name,
+			Set:    methods.unique("Set" + cfg.public(el.Name)).(*ast.Ident).Name,
+			Item:   item,
+			Type:   item,
+			Plural: el.Plural,
+		}
+		if el.Plural {
+			alt.Type = "[]" + item
+		}
+		if cfg.usesHelper(el.Type) {
+			h, ok := cfg.helperTypes[xsd.XMLName(el.Type)]
+			if !ok {
+				return spec{}, fmt.Errorf("no helper type for element %s", el.Name.Local)
+			}
+			helperTypes = append(helperTypes, xsd.XMLName(h.xsdType))
+			alt.Helper = h.name
+		}
+		data.Alternatives = append(data.Alternatives, alt)
+	}
+
+	s := spec{
+		doc: fmt.Sprintf("%s holds one of the elements %s of %s.",
+			data.Type, data.Names, data.Parent),
+		name: data.Type,
+		expr: gen.Struct(
+			ast.NewIdent("name"), ast.NewIdent("string"), nil,
+			ast.NewIdent("value"), ast.NewIdent("interface{}"), nil),
+		xsdType: &xsd.ComplexType{
+			Name:      xml.Name{t.Name.Space, t.Name.Local + "Choice"},
+			Anonymous: true,
+		},
+		helperTypes: helperTypes,
+	}
+
+	which, err := gen.Func("Which").
+		Receiver("c " + data.Type).
+		Returns("string").
+		Comment("Which returns the name of the element held by c, or the\nempty string if c is empty.").
+		Body(`return c.name`).
+		Decl()
+	if err != nil {
+		return s, err
+	}
+	s.methods = append(s.methods, which)
+
+	for _, alt := range data.Alternatives {
+		get, err := gen.Func(alt.Get).
+			Receiver("c "+data.Type).
+			Returns("v "+alt.Type, "ok bool").
+			Comment(fmt.Sprintf("%s returns the value of the %s element, if c holds one.",
+				alt.Get, alt.Name.Local)).
+			Body(`
+				if c.name == %q {
+					v, ok = c.value.(%s)
+				}
+				return v, ok
+			`, alt.Name.Local, alt.Type).
+			Decl()
+		if err != nil {
+			return s, err
+		}
+		set, err := gen.Func(alt.Set).
+			Receiver("c *"+data.Type).
+			Args("v "+alt.Type).
+			Comment(fmt.Sprintf("%s replaces the contents of c with the %s element.",
+				alt.Set, alt.Name.Local)).
+			Body(`c.name, c.value = %q, v`, alt.Name.Local).
+			Decl()
+		if err != nil {
+			return s, err
+		}
+		s.methods = append(s.methods, get, set)
+	}
+
+	unmarshal, err := gen.Func("UnmarshalXML").
+		Receiver("c *"+data.Type).
+		Args("d *xml.Decoder", "start xml.StartElement").
+		Returns("error").
+		BodyTmpl(`
+			switch {
+			{{- range .Alternatives}}
+			case start.Name.Local == {{printf "%q" .Name.Local}}
+				{{- if .Name.Space}} && start.Name.Space == {{printf "%q" .Name.Space}}{{end}}:
+				{{- if .Plural}}
+				if c.name != "" && c.name != {{printf "%q" .Name.Local}} {
+					return fmt.Errorf("{{$.Parent}} may contain only one of {{$.Names}}; found %s and %s",
+						c.name, start.Name.Local)
+				}
+				{{- else}}
+				if c.name != "" {
+					return fmt.Errorf("{{$.Parent}} may contain only one of {{$.Names}}; found %s and %s",
+						c.name, start.Name.Local)
+				}
+				{{- end}}
+				var v {{if .Helper}}{{.Helper}}{{else}}{{.Item}}{{end}}
+				if err := d.DecodeElement(&v, &start); err != nil {
+					return err
+				}
+				{{- if .Plural}}
+				items, _ := c.value.({{.Type}})
+				c.name, c.value = {{printf "%q" .Name.Local}}, append(items, {{if .Helper}}{{.Item}}(v){{else}}v{{end}})
+				{{- else}}
+				c.name, c.value = {{printf "%q" .Name.Local}}, {{if .Helper}}{{.Item}}(v){{else}}v{{end}}
+				{{- end}}
+				return nil
+			{{- end}}
+			}
+			return d.Skip()
+		`, data).Decl()
+	if err != nil {
+		return s, err
+	}
+
+	marshal, err := gen.Func("MarshalXML").
+		Receiver("c "+data.Type).
+		Args("e *xml.Encoder", "start xml.StartElement").
+		Returns("error").
+		BodyTmpl(`
+			switch c.name {
+			{{- range .Alternatives}}
+			case {{printf "%q" .Name.Local}}:
+				start = xml.StartElement{Name: xml.Name{Space: {{printf "%q" .Name.Space}}, Local: {{printf "%q" .Name.Local}}}}
+				{{- if .Plural}}
+				items, _ := c.value.({{.Type}})
+				for _, v := range items {
+					if err := e.EncodeElement({{if .Helper}}{{.Helper}}(v){{else}}v{{end}}, start); err != nil {
+						return err
+					}
+				}
+				return nil
+				{{- else}}
+				v, _ := c.value.({{.Type}})
+				return e.EncodeElement({{if .Helper}}{{.Helper}}(v){{else}}v{{end}}, start)
+				{{- end}}
+			{{- end}}
+			}
+			{{if .Required -}}
+			return errors.New("{{.Parent}} must contain one of {{.Names}}")
+			{{- else -}}
+			return nil
+			{{- end}}
+		`, data).Decl()
+	if err != nil {
+		return s, err
+	}
+	s.methods = append(s.methods, unmarshal, marshal)
+	return s, nil
+}
+
 func (cfg *Config) genSimpleType(t *xsd.SimpleType) ([]spec, error) {
 	var result []spec
 	if t.List {
 		return cfg.genSimpleListSpec(t)
 	}
 	if len(t.Union) > 0 {
-		// We don't support unions because the code that needs
-		// to be generated to check which of the member types
-		// the value would be too complex. Need a use case
-		// first.
-		result = append(result, spec{
-			doc:     t.Doc,
-			name:    cfg.public(t.Name),
-			expr:    builtinExpr(xsd.String),
-			xsdType: t,
-		})
-		return result, nil
+		return cfg.genSimpleUnionSpec(t)
 	}
 	base, err := cfg.expr(t.Base)
 	if err != nil {
@@ -898,13 +1740,165 @@ func (cfg *Config) genSimpleType(t *xsd.SimpleType) ([]spec, error) {
 	if err != nil {
 		return result, err
 	}
+	if len(t.Restriction.Enum) > 0 {
+		spec = cfg.addEnumConstants(spec)
+	}
 	return append(result, spec), nil
 }
 
+// Declare a constant for each value of an enumerated simple type,
+// along with an IsValid method.
+func (cfg *Config) addEnumConstants(s spec) spec {
+	t := s.xsdType.(*xsd.SimpleType)
+	base, ok := t.Base.(xsd.Builtin)
+	if !ok || cfg.usesHelper(base) {
+		return s
+	}
+	var (
+		consts   func(...string) *ast.GenDecl
+		parse    func(string) (string, bool)
+		numeric  = true
+		args     []string
+		names    []string
+		seen     = make(map[string]bool)
+		namegen  = nameGenerator{cfg, make(map[string]struct{})}
+		baseType = cfg.exprString(base)
+	)
+	switch base {
+	case xsd.Byte, xsd.Int, xsd.Integer, xsd.Long, xsd.NegativeInteger,
+		xsd.NonNegativeInteger, xsd.NonPositiveInteger, xsd.PositiveInteger,
+		xsd.Short:
+		consts = gen.ConstInt
+		parse = func(v string) (string, bool) {
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return strconv.FormatInt(n, 10), err == nil
+		}
+	case xsd.UnsignedByte, xsd.UnsignedInt, xsd.UnsignedLong, xsd.UnsignedShort:
+		// Values of an unsignedLong may be too large for an int64.
+		consts = gen.ConstInt
+		parse = func(v string) (string, bool) {
+			n, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(v), "+"), 10, 64)
+			return strconv.FormatUint(n, 10), err == nil
+		}
+	case xsd.Decimal, xsd.Double, xsd.Float:
+		if base == xsd.Decimal && cfg.arbitraryPrecision {
+			consts, numeric = gen.ConstString, false
+			parse = func(v string) (string, bool) {
+				return strings.TrimSpace(v), true
+			}
+			break
+		}
+		consts = gen.ConstFloat
+		parse = func(v string) (string, bool) {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return strconv.FormatFloat(f, 'g', -1, 64), err == nil
+		}
+	case xsd.Boolean:
+		cfg.debugf("simpleType %s: not declaring constants for boolean enumeration",
+			t.Name.Local)
+		return s
+	default:
+		consts, numeric = gen.ConstString, false
+		parse = func(v string) (string, bool) { return v, true }
+	}
+	for _, v := range t.Restriction.Enum {
+		value, ok := parse(v)
+		if !ok {
+			cfg.logf("simpleType %s: invalid %s enumeration value %q",
+				t.Name.Local, base, v)
+			return s
+		}
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		label := strings.Title(strings.TrimSpace(v))
+		if numeric && strings.HasPrefix(label, "-") {
+			label = "Minus" + label[1:]
+		}
+		name := namegen.unique(s.name + enumConstName(cfg.public(xml.Name{t.Name.Space, label}))).(*ast.Ident).Name
+		args = append(args, name, s.name, value)
+		names = append(names, name)
+	}
+	s.decls = append(s.decls, consts(args...))
+	s.methods = append(s.methods, gen.Func("IsValid").
+		Receiver("v "+s.name).
+		Returns("bool").
+		Comment("IsValid returns true if v is one of the enumerated values of "+s.name+".").
+		Body(`
+			switch v {
+			case %s:
+				return true
+			}
+			return false
+		`, strings.Join(names, ", ")).
+		MustDecl())
+
+	if !cfg.strictEnums {
+		return s
+	}
+	unmarshal := gen.Func("UnmarshalText").
+		Receiver("v *" + s.name).
+		Args("text []byte").
+		Returns("error")
+	switch {
+	case base == xsd.String:
+		unmarshal = unmarshal.Body(`
+			if x := %s(text); x.IsValid() {
+				*v = x
+				return nil
+			}
+			return fmt.Errorf("%%q is not a valid %[1]s", text)
+		`, s.name)
+	case baseType == "string":
+		unmarshal = unmarshal.Body(`
+			if x := %s(bytes.TrimSpace(text)); x.IsValid() {
+				*v = x
+				return nil
+			}
+			return fmt.Errorf("%%q is not a valid %[1]s", text)
+		`, s.name)
+	default:
+		s.helperFuncs = append(s.helperFuncs, "_unmarshalText")
+		unmarshal = unmarshal.Body(`
+			var x %s
+			if err := _unmarshalText(text, &x); err != nil {
+				return err
+			}
+			if x := %s(x); x.IsValid() {
+				*v = x
+				return nil
+			}
+			return fmt.Errorf("%%q is not a valid %[2]s", text)
+		`, baseType, s.name)
+	}
+	// replaces the UnmarshalText method added by addSpecMethods, if any
+	s.methods = append(removeMethod(s.methods, "UnmarshalText"), unmarshal.MustDecl())
+	return s
+}
+
+// enumConstName removes any characters from an enumerated value
+// that cannot be part of a Go identifier.
+func enumConstName(value string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, value)
+	if name == "" {
+		return "Value"
+	}
+	return strings.Title(name)
+}
+
 // Attach Marshal/Unmarshal methods to a simple type, if necessary.
 func (cfg *Config) addSpecMethods(s spec) (spec, error) {
 	t, ok := s.xsdType.(*xsd.SimpleType)
-	if !ok || !nonTrivialBuiltin(t.Base) {
+	if !ok {
+		return s, nil
+	}
+	if !cfg.usesHelper(t.Base) && !(cfg.arbitraryPrecision && t.Base == xsd.Decimal) {
 		return s, nil
 	}
 
@@ -932,6 +1926,231 @@ func (cfg *Config) addSpecMethods(s spec) (spec, error) {
 	return s, nil
 }
 
+// Anonymous member types of a <union> are named after the union
+// and their position in it, such as DeadlineTypeMember2, rather
+// than by the order the parser came across them.
+func nameUnionMembers(types map[xml.Name]xsd.Type) {
+	for _, t := range types {
+		u, ok := t.(*xsd.SimpleType)
+		if !ok {
+			continue
+		}
+		for i, member := range u.Union {
+			m, ok := member.(*xsd.SimpleType)
+			if !ok || !m.Anonymous {
+				continue
+			}
+			name := xml.Name{m.Name.Space, fmt.Sprintf("%sMember%d", u.Name.Local, i+1)}
+			if _, inuse := types[name]; !inuse {
+				m.Name = name
+			}
+		}
+	}
+}
+
+type unionMember struct {
+	// Name of the accessor methods, also returned by Which
+	Name string
+	// Go type of the member, and the helper type used to
+	// marshal it, if any.
+	Item, Helper string
+	// True if the Go type has its own MarshalText and
+	// UnmarshalText methods.
+	Text bool
+	// Permitted values, if the member is an enumeration
+	Enum []string
+	// Variable holding the pattern values of the member must
+	// match, if any
+	Pattern string
+}
+
+// Generate a type declaration for a <union> type. The value of a union
+// is parsed as each of its member types in turn, and the first one
+// that succeeds is kept, along with the name of the member type.
+func (cfg *Config) genSimpleUnionSpec(t *xsd.SimpleType) ([]spec, error) {
+	cfg.debugf("generating Go source for simple union %q", xsd.XMLName(t).Local)
+	var data struct {
+		Type    string
+		Members []unionMember
+	}
+	data.Type = cfg.public(t.Name)
+
+	s := spec{
+		doc:     t.Doc,
+		name:    data.Type,
+		xsdType: t,
+		expr: gen.Struct(
+			ast.NewIdent("member"), ast.NewIdent("string"), nil,
+			ast.NewIdent("value"), ast.NewIdent("interface{}"), nil),
+		helperFuncs: []string{"_unmarshalText"},
+	}
+	methods := nameGenerator{cfg, map[string]struct{}{
+		"Which":          struct{}{},
+		"MarshalText":    struct{}{},
+		"UnmarshalText":  struct{}{},
+		"MarshalXML":     struct{}{},
+		"MarshalXMLAttr": struct{}{},
+	}}
+	var names []string
+	for _, member := range t.Union {
+		item := cfg.exprString(member)
+		if item == "" {
+			return nil, fmt.Errorf("union %s: unknown member type %s",
+				t.Name.Local, xsd.XMLName(member).Local)
+		}
+		m := unionMember{Item: item}
+		switch member := member.(type) {
+		case xsd.Builtin:
+			m.Name = cfg.public(member.Name())
+			if cfg.usesHelper(member) {
+				h, ok := cfg.helperTypes[member.Name()]
+				if !ok {
+					return nil, fmt.Errorf("union %s: no helper type for %v", t.Name.Local, member)
+				}
+				s.helperTypes = append(s.helperTypes, xsd.XMLName(h.xsdType))
+				m.Helper = h.name
+			}
+		case *xsd.SimpleType:
+			m.Name = strings.Title(item)
+			if member.Anonymous {
+				m.Name = fmt.Sprintf("Member%d", len(data.Members)+1)
+			}
+			m.Text = member.List || len(member.Union) > 0 || cfg.usesHelper(member.Base)
+			m.Enum = member.Restriction.Enum
+			if r := member.Restriction.Pattern; r != nil && !member.List && len(member.Union) == 0 {
+				m.Pattern = "_" + data.Type + "Match" + item
+				s.decls = append(s.decls, patternDecl(m.Pattern, r.String()))
+			}
+		default:
+			return nil, fmt.Errorf("union %s: member %s is not a simple type",
+				t.Name.Local, xsd.XMLName(member).Local)
+		}
+		m.Name = methods.unique(m.Name).(*ast.Ident).Name
+		names = append(names, xsd.XMLName(member).Local)
+		data.Members = append(data.Members, m)
+	}
+	if s.doc == "" {
+		s.doc = "Holds a value of one of the types " + strings.Join(names, ", ")
+	}
+
+	which, err := gen.Func("Which").
+		Receiver("u " + data.Type).
+		Returns("string").
+		Comment("Which returns the name of the member type of the value held\nby u, or the empty string if u is empty.").
+		Body(`return u.member`).
+		Decl()
+	if err != nil {
+		return nil, err
+	}
+	s.methods = append(s.methods, which)
+
+	for _, m := range data.Members {
+		get, err := gen.Func(m.Name).
+			Receiver("u "+data.Type).
+			Returns("v "+m.Item, "ok bool").
+			Comment(fmt.Sprintf("%s returns the value held by u, if it is a %s.", m.Name, m.Item)).
+			Body(`
+				if u.member == %q {
+					v, ok = u.value.(%s)
+				}
+				return v, ok
+			`, m.Name, m.Item).
+			Decl()
+		if err != nil {
+			return nil, err
+		}
+		set, err := gen.Func("Set"+m.Name).
+			Receiver("u *"+data.Type).
+			Args("v "+m.Item).
+			Comment(fmt.Sprintf("Set%s replaces the value held by u with a %s.", m.Name, m.Item)).
+			Body(`u.member, u.value = %q, v`, m.Name).
+			Decl()
+		if err != nil {
+			return nil, err
+		}
+		s.methods = append(s.methods, get, set)
+	}
+
+	unmarshal, err := gen.Func("UnmarshalText").
+		Receiver("u *"+data.Type).
+		Args("text []byte").
+		Returns("error").
+		BodyTmpl(`
+			{{- range .Members}}
+			{{if .Enum -}}
+			switch strings.TrimSpace(string(text)) {
+			case {{range $i, $v := .Enum}}{{if $i}}, {{end}}{{printf "%q" $v}}{{end}}:
+			{{- else if .Pattern -}}
+			if {{.Pattern}}.Match(text) {
+			{{- else -}}
+			{
+			{{- end}}
+				var v {{if .Helper}}{{.Helper}}{{else}}{{.Item}}{{end}}
+				if err := {{if or .Helper .Text}}v.UnmarshalText(text){{else}}_unmarshalText(text, &v){{end}}; err == nil {
+					u.member, u.value = {{printf "%q" .Name}}, {{if .Helper}}{{.Item}}(v){{else}}v{{end}}
+					return nil
+				}
+			}
+			{{- end}}
+			return fmt.Errorf("%q is not a valid {{.Type}}", text)
+		`, data).Decl()
+	if err != nil {
+		return nil, err
+	}
+
+	marshal, err := gen.Func("MarshalText").
+		Receiver("u "+data.Type).
+		Returns("[]byte", "error").
+		BodyTmpl(`
+			switch u.member {
+			{{- range .Members}}
+			case {{printf "%q" .Name}}:
+				v, _ := u.value.({{.Item}})
+				{{if .Helper -}}
+				return {{.Helper}}(v).MarshalText()
+				{{- else if .Text -}}
+				return v.MarshalText()
+				{{- else -}}
+				return []byte(fmt.Sprint(v)), nil
+				{{- end}}
+			{{- end}}
+			}
+			return nil, nil
+		`, data).Decl()
+	if err != nil {
+		return nil, err
+	}
+	s.methods = append(s.methods, unmarshal, marshal,
+		// An empty union is omitted, rather than marshalled as
+		// an empty string.
+		gen.Func("MarshalXML").
+			Receiver("u "+data.Type).
+			Args("e *xml.Encoder", "start xml.StartElement").
+			Returns("error").
+			Body(`
+				if u.member == "" {
+					return nil
+				}
+				m, err := u.MarshalText()
+				if err != nil {
+					return err
+				}
+				return e.EncodeElement(m, start)
+			`).MustDecl(),
+		gen.Func("MarshalXMLAttr").
+			Receiver("u "+data.Type).
+			Args("name xml.Name").
+			Returns("xml.Attr", "error").
+			Body(`
+				if u.member == "" {
+					return xml.Attr{}, nil
+				}
+				m, err := u.MarshalText()
+				return xml.Attr{Name: name, Value: string(m)}, err
+			`).MustDecl())
+	return []spec{s}, nil
+}
+
 // Generate a type declaration for a <list> type, along with marshal/unmarshal
 // methods.
 func (cfg *Config) genSimpleListSpec(t *xsd.SimpleType) ([]spec, error) {
@@ -959,8 +2178,12 @@ func (cfg *Config) genSimpleListSpec(t *xsd.SimpleType) ([]spec, error) {
 		base = xsd.Base(base)
 	}
 
+	if b, ok := base.(xsd.Builtin); ok && cfg.arbitraryPrecision && bigNumberExpr(b) != nil {
+		return cfg.genBigNumberListSpec(s, b)
+	}
+
 	switch base.(xsd.Builtin) {
-	case xsd.ID, xsd.NCName, xsd.NMTOKEN, xsd.Name, xsd.QName, xsd.ENTITY, xsd.AnyURI, xsd.Language, xsd.String, xsd.Token, xsd.XMLLang, xsd.XMLSpace, xsd.XMLBase, xsd.XMLId, xsd.Duration, xsd.NormalizedString, xsd.AnySimpleType:
+	case xsd.ID, xsd.NCName, xsd.NMTOKEN, xsd.Name, xsd.QName, xsd.ENTITY, xsd.AnyURI, xsd.Language, xsd.String, xsd.Token, xsd.XMLLang, xsd.XMLSpace, xsd.XMLBase, xsd.XMLId, xsd.NormalizedString, xsd.AnySimpleType:
 		marshalFn = marshalFn.Body(`
 			result := make([][]byte, 0, len(*x))
 			for _, v := range *x {
@@ -974,17 +2197,20 @@ func (cfg *Config) genSimpleListSpec(t *xsd.SimpleType) ([]spec, error) {
 			}
 			return nil
 		`)
-	case xsd.Date, xsd.DateTime, xsd.GDay, xsd.GMonth, xsd.GMonthDay, xsd.GYear, xsd.GYearMonth, xsd.Time:
+	case xsd.Date, xsd.DateTime, xsd.GDay, xsd.GMonth, xsd.GMonthDay, xsd.GYear, xsd.GYearMonth, xsd.Time, xsd.Duration:
+		if base == xsd.Duration || cfg.preserveTimeFormat {
+			s.helperTypes = append(s.helperTypes, xsd.XMLName(base))
+		}
 		marshalFn = marshalFn.Body(`
 			result := make([][]byte, 0, len(*x))
 			for _, v := range *x {
 				if b, err := v.MarshalText(); err != nil {
-					return result, err
+					return nil, err
 				} else {
 					result = append(result, b)
 				}
 			}
-			return bytes.Join(result, []byte(" "))
+			return bytes.Join(result, []byte(" ")), nil
 		`)
 		unmarshalFn = unmarshalFn.Body(`
 			for _, v := range bytes.Fields(text) {
@@ -994,7 +2220,8 @@ func (cfg *Config) genSimpleListSpec(t *xsd.SimpleType) ([]spec, error) {
 				}
 				*x = append(*x, t)
 			}
-		`, builtinExpr(base.(xsd.Builtin)).(*ast.Ident).Name)
+			return nil
+		`, cfg.exprString(base))
 	case xsd.Long:
 		marshalFn = marshalFn.Body(`
 			result := make([][]byte, 0, len(*x))
@@ -1135,6 +2362,52 @@ func (cfg *Config) genSimpleListSpec(t *xsd.SimpleType) ([]spec, error) {
 	return []spec{s}, nil
 }
 
+// Generate the methods of a <list> of decimals or integers, when using
+// the ArbitraryPrecision option. Both XSDDecimal and big.Int implement
+// encoding.TextMarshaler.
+func (cfg *Config) genBigNumberListSpec(s spec, base xsd.Builtin) ([]spec, error) {
+	item, value := "new(big.Int)", "t"
+	if base == xsd.Decimal {
+		item, value = "new(XSDDecimal)", "*t"
+	}
+	marshal, err := gen.Func("MarshalText").
+		Receiver("x *"+s.name).
+		Returns("[]byte", "error").
+		Body(`
+			result := make([][]byte, 0, len(*x))
+			for _, v := range *x {
+				b, err := v.MarshalText()
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, b)
+			}
+			return bytes.Join(result, []byte(" ")), nil
+		`).Decl()
+	if err != nil {
+		return nil, fmt.Errorf("MarshalText %s: %v", s.name, err)
+	}
+	unmarshal, err := gen.Func("UnmarshalText").
+		Receiver("x *"+s.name).
+		Args("text []byte").
+		Returns("error").
+		Body(`
+			for _, v := range bytes.Fields(text) {
+				t := %s
+				if err := t.UnmarshalText(v); err != nil {
+					return err
+				}
+				*x = append(*x, %s)
+			}
+			return nil
+		`, item, value).Decl()
+	if err != nil {
+		return nil, fmt.Errorf("UnmarshalText %s: %v", s.name, err)
+	}
+	s.methods = append(s.methods, marshal, unmarshal)
+	return []spec{s}, nil
+}
+
 // O(n²) is OK since you'll never see more than ~40 attributes...
 // right?
 func mergeAttributes(src, base *xsd.ComplexType) []xsd.Attribute {
@@ -1,10 +1,18 @@
 package xsdgen
 
 import (
+	"encoding/xml"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"io/ioutil"
 	"os"
 	"regexp"
 	"testing"
+
+	"aqwari.net/xml/internal/gen"
 )
 
 type testLogger testing.T
@@ -59,6 +67,42 @@ func testGen(t *testing.T, ns string, files ...string) string {
 	return string(data)
 }
 
+// typeCheck reports an error if the generated source does not
+// compile.
+func typeCheck(t *testing.T, data string) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "generated.go", data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	if _, err := conf.Check(file.Name.Name, fset, []*ast.File{file}, nil); err != nil {
+		t.Errorf("generated code does not compile: %v", err)
+	}
+}
+
+func TestValidateWSDL(t *testing.T) {
+	data := testGen(t, "http://schemas.xmlsoap.org/wsdl/", "-validate", "testdata/wsdl.xsd")
+	typeCheck(t, data)
+}
+
+func TestValidateBounds(t *testing.T) {
+	data := testGen(t, "http://example.org/", "-validate", "testdata/bounds.xsd")
+	for _, pattern := range []string{
+		`if float64\(v\) < 0 {`,
+		`if float64\(v\) >= 100 {`,
+		`if float64\(v\) <= 0 {`,
+		`if float64\(v\) > 1 {`,
+		`if !time.Time\(v\).After\(time.Unix\(946684800, 0\)\) {`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	typeCheck(t, data)
+	t.Logf("%s\n", data)
+}
+
 func TestMixedType(t *testing.T) {
 	data := testGen(t, "http://example.org", "testdata/mixed-complex.xsd")
 	if !grep(`PositiveNumber[^}]*,chardata`, data) {
@@ -73,5 +117,549 @@ func TestBase64Binary(t *testing.T) {
 }
 
 func TestSimpleUnion(t *testing.T) {
-	t.Logf("%s\n", testGen(t, "http://example.org/", "testdata/simple-union.xsd"))
+	data := testGen(t, "http://example.org/", "testdata/simple-union.xsd")
+	for _, pattern := range []string{
+		`func \(u ConditionalUintType\) UnsignedInt\(\) \(v uint, ok bool\)`,
+		`func \(u \*ConditionalUintType\) SetBoolean\(v bool\)`,
+		`func \(u DeadlineType\) Date\(\) \(v time.Time, ok bool\)`,
+		`case "ASAP":`,
+		`case "never":`,
+		`type DeadlineTypeMember2 string`,
+		`const DeadlineTypeMember2ASAP DeadlineTypeMember2 = "ASAP"`,
+		`func \(u DeadlineType\) Member3\(\) \(v DeadlineTypeMember3, ok bool\)`,
+		`// Holds a value of one of the types date, DeadlineTypeMember2, DeadlineTypeMember3`,
+		`if _IdentifierTypeMatchCodeType.Match\(text\) {`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	if grep(`Anon[0-9]`, data) {
+		t.Error("generated code names anonymous union members by their parse order")
+	}
+	typeCheck(t, data)
+	t.Logf("%s\n", data)
+}
+
+func TestEnumConstants(t *testing.T) {
+	data := testGen(t, "http://example.org/", "-strictenums", "testdata/enum.xsd")
+	for _, pattern := range []string{
+		`Checksum0 +Checksum = 0`,
+		`Checksum18446744073709551615 +Checksum = 18446744073709551615`,
+		`func \(v Checksum\) IsValid\(\) bool`,
+		`OffsetMinus1 +Offset = -1`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	typeCheck(t, data)
+	t.Logf("%s\n", data)
+}
+
+func TestDuration(t *testing.T) {
+	data := testGen(t, "http://example.org/", "testdata/duration.xsd")
+	for _, pattern := range []string{
+		`type XSDDuration struct`,
+		`func \(d \*XSDDuration\) UnmarshalText\(text \[\]byte\) error`,
+		`func \(d XSDDuration\) Duration\(\) \(time.Duration, bool\)`,
+		`type Timeout XSDDuration`,
+		`type Intervals \[\]XSDDuration`,
+		`Wait +XSDDuration`,
+		`func \(d XSDDuration\) MarshalText\(\) \(\[\]byte, error\)`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	typeCheck(t, data)
+	t.Logf("%s\n", data)
+}
+
+func TestArbitraryPrecision(t *testing.T) {
+	var cfg Config
+	cfg.Option(DefaultOptions...)
+	cfg.Option(ArbitraryPrecision(true), LogOutput((*testLogger)(t)))
+
+	out, err := cfg.GenSource("testdata/bignum.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := string(out)
+	for _, pattern := range []string{
+		`type XSDDecimal string`,
+		`func \(d XSDDecimal\) Rat\(\) \(\*big.Rat, bool\)`,
+		`type Price XSDDecimal`,
+		`type Serials \[\]\*big.Int`,
+		`Number +\*big.Int`,
+		`Rate +XSDDecimal`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	t.Logf("%s\n", data)
+}
+
+func TestPreserveTimeFormat(t *testing.T) {
+	var cfg Config
+	cfg.Option(DefaultOptions...)
+	cfg.Option(PreserveTimeFormat(true), LogOutput((*testLogger)(t)))
+
+	out, err := cfg.GenSource("testdata/timezone.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := string(out)
+	for _, pattern := range []string{
+		`type XSDDateTime struct`,
+		`HasTimezone +bool`,
+		`func \(t \*XSDDate\) UnmarshalText\(text \[\]byte\) error`,
+		`func _parseTime\(`,
+		`type OpeningDay XSDDate`,
+		`type Holidays \[\]XSDDate`,
+		`Opens +XSDTime`,
+		`Founded +XSDGYear`,
+		`Inspected +\[\]XSDDateTime`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	if grep(`_marshalTime`, data) {
+		t.Error("generated code uses _marshalTime")
+	}
+	t.Logf("%s\n", data)
+}
+
+func TestGroupStructs(t *testing.T) {
+	var cfg Config
+	cfg.Option(DefaultOptions...)
+	cfg.Option(GroupStructs(true), LogOutput((*testLogger)(t)))
+
+	out, err := cfg.GenSource("testdata/groups.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := string(out)
+	for _, pattern := range []string{
+		`type NameGroup struct`,
+		`type ContactGroup struct {\s+NameGroup\s+Email +\[\]string`,
+		`type AuditAttrs struct`,
+		`type Person struct {\s+ContactGroup\s+Age +int[^}]+AuditAttrs\s+}`,
+		`type Company struct {\s+Title +string[^}]+NameGroup\s+AuditAttrs\s+}`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	if grep(`Given +string[^}]+Given +string`, data) {
+		t.Error("group members are declared more than once")
+	}
+	t.Logf("%s\n", data)
+}
+
+func TestSubstitutionGroups(t *testing.T) {
+	var cfg Config
+	cfg.Option(DefaultOptions...)
+	cfg.Option(SubstitutionGroups(true), LogOutput((*testLogger)(t)))
+
+	out, err := cfg.GenSource("testdata/substitution.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := string(out)
+	for _, pattern := range []string{
+		`type ShapeElement interface`,
+		`Shape +\[\]ShapeElement +` + "`" + `xml:",any"` + "`",
+		`Polygon +PolygonElement +` + "`" + `xml:",any"` + "`",
+		`func \(Circle\) isShapeElement\(\)`,
+		`func \(Polygon\) isShapeElement\(\)`,
+		`func \(Polygon\) isPolygonElement\(\)`,
+		`case start.Name.Local == "square"`,
+		`layout.Shape = &xsdShapeElementList{&layout.T.Shape}`,
+		`type Square struct {\s+Polygon\s+}`,
+		`case Square, \*Square:\s+start = xml.StartElement{Name: xml.Name{Space: "http://example.org/shapes", Local: "square"}}`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	if grep(`func \(Shape\) isShapeElement`, data) {
+		t.Error("abstract element shape is a member of its own substitution group")
+	}
+	typeCheck(t, data)
+	t.Logf("%s\n", data)
+}
+
+func TestDerivedTypes(t *testing.T) {
+	var cfg Config
+	cfg.Option(DefaultOptions...)
+	cfg.Option(DerivedTypes(true), LogOutput((*testLogger)(t)))
+
+	out, err := cfg.GenSource("testdata/derived.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := string(out)
+	for _, pattern := range []string{
+		`type AnyAnimal interface`,
+		`type AnyDog interface`,
+		`Mascot +AnyAnimal +` + "`" + `xml:"http://example.org/zoo mascot,omitempty"` + "`",
+		`Animal +\[\]AnyAnimal +`,
+		`Guard +AnyDog +`,
+		`func \(\*Animal\) isAnyAnimal\(\)`,
+		`func \(\*Puppy\) isAnyAnimal\(\)`,
+		`func \(\*Puppy\) isAnyDog\(\)`,
+		`Local: "puppy"}: func\(\) AnyDog`,
+		`layout.Animal = &xsdAnyAnimalList{&layout.T.Animal}`,
+		`}\n\n// AnyDog is implemented by pointers to Dog and to the types derived\n// from it.[^\n]*\ntype AnyDog interface`,
+		`return fmt.Errorf\("xsi:type %s is ambiguous`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	if grep(`func \(\*Cat\) isAnyDog`, data) {
+		t.Error("cat implements the interface of dog")
+	}
+	if grep(`func \(\*Dog\) isAnyAnimal\(\) {\s+}\s+func \(\*Dog\) isAnyAnimal`, data) {
+		t.Error("isAnyAnimal declared twice for Dog")
+	}
+	typeCheck(t, data)
+	t.Logf("%s\n", data)
+
+	// A mapped base type is not declared, so its fields keep
+	// their type.
+	cfg.Option(MapType(xml.Name{"http://example.org/zoo", "dog"}, "", "Hound"))
+	out, err = cfg.GenSource("testdata/derived.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = string(out)
+	if !grep(`Guard +Hound +`, data) {
+		t.Error("field of mapped base type is not declared with the mapped type")
+	}
+	if grep(`AnyDog`, data) {
+		t.Error("interface declared for mapped base type")
+	}
+	t.Logf("%s\n", data)
+}
+
+func TestNillableElements(t *testing.T) {
+	var cfg Config
+	cfg.Option(DefaultOptions...)
+	cfg.Option(NillableElements(true), LogOutput((*testLogger)(t)))
+
+	out, err := cfg.GenSource("testdata/nillable.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := string(out)
+	for _, pattern := range []string{
+		`Nickname +NillableString +` + "`" + `xml:"http://example.org/contact nickname"` + "`",
+		`Birthday +\*NillableDate +` + "`" + `xml:"http://example.org/contact birthday,omitempty"` + "`",
+		`Phone +\[\]NillablePhone +`,
+		`Address +\*NillableAddress +`,
+		`type NillableDate struct {\s+Value +time.Time\s+Nil +bool`,
+		`return d.DecodeElement\(\(\*xsdDate\)\(&v.Value\), &start\)`,
+		`if _xsiNil\(start\) {`,
+		`Name: xml.Name{Local: "xsi:nil"}, Value: "true"`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	if grep(`Name +Nillable`, data) {
+		t.Error("element name is not nillable, but declared with a wrapper")
+	}
+	t.Logf("%s\n", data)
+}
+
+func TestOptionalPointers(t *testing.T) {
+	var cfg Config
+	cfg.Option(DefaultOptions...)
+	cfg.Option(OptionalPointers(true), LogOutput((*testLogger)(t)))
+
+	out, err := cfg.GenSource("testdata/optional.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := string(out)
+	for _, pattern := range []string{
+		`Sku +Sku +`,
+		`Quantity +\*int +`,
+		`Gift +\*bool +`,
+		`Code +\*Sku +`,
+		`Shipped +time.Time +`,
+		`Tags +Tags +`,
+		`Duration +\*XSDDuration +`,
+		`Discount +\*float64 +` + "`" + `xml:"discount,attr,omitempty"` + "`",
+		`Priority +\*int +`,
+		`Customer +string +` + "`" + `xml:"customer,attr"` + "`",
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	t.Logf("%s\n", data)
+}
+
+func TestDefaultAndFixedValues(t *testing.T) {
+	data := testGen(t, "http://example.org/invoice", "testdata/fixed.xsd")
+	for _, pattern := range []string{
+		`func NewInvoice\(\) \*Invoice {`,
+		`t.Currency = "USD"`,
+		`t.TaxRate = 0.2`,
+		`t.Unit = "pcs"`,
+		`t.Version = "2.1"`,
+		`t.Copies = 1`,
+		`Version +string +` + "`" + `xml:"version,attr"` + "`",
+		`layout.Version = "2.1"`,
+		`func NewNote\(\) \*Note {`,
+		`layout.Kind = "note"`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	if grep(`t.Paid = false`, data) {
+		t.Error("constructor sets a field to its zero value")
+	}
+	if grep(`func \(t \*Note\) UnmarshalXML`, data) {
+		t.Error("UnmarshalXML method generated for a fixed value")
+	}
+	t.Logf("%s\n", data)
+}
+
+func TestRootElements(t *testing.T) {
+	data := testGen(t, "http://example.org/billing", "-roots", "testdata/root.xsd")
+	for _, pattern := range []string{
+		`type InvoiceDocument struct {\s+XMLName +xml.Name +` + "`" + `xml:"http://example.org/billing invoice"` + "`" + `\s+Invoice\s+}`,
+		`func ParseInvoiceDocument\(r io.Reader\) \(\*InvoiceDocument, error\)`,
+		`t.Invoice = \*NewInvoice\(\)`,
+		`type Order struct {\s+XMLName +xml.Name +` + "`" + `xml:"http://example.org/billing order"` + "`" + `\s+OrderType\s+}`,
+		`func \(t Order\) WriteTo\(w io.Writer\) \(int64, error\)`,
+		`func ParseOrder\(r io.Reader\) \(\*Order, error\)`,
+		`type StatusDocument struct {\s+XMLName +xml.Name [^\n]+\s+Value +Status\s+}`,
+		`type Comment struct {\s+XMLName +xml.Name [^\n]+\s+Value +string\s+}`,
+		`d.DecodeElement\(\(\*xsdDate\)\(&t.Value\), &start\)`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	if grep(`type Document(Document)? struct`, data) {
+		t.Error("wrapper type declared for an abstract element")
+	}
+	typeCheck(t, data)
+	t.Logf("%s\n", data)
+}
+
+func TestFollowImports(t *testing.T) {
+	data := testGen(t, "http://example.org/order", "-f", "testdata/imports/order.xsd")
+	for _, pattern := range []string{
+		`type Order struct`,
+		`Customer +Party`,
+		`Total +float64`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	t.Logf("%s\n", data)
+}
+
+func TestPackagePaths(t *testing.T) {
+	var cfg Config
+	cfg.Option(DefaultOptions...)
+	cfg.Option(LogOutput((*testLogger)(t)))
+	cfg.Option(FollowImports(true), Namespaces("http://example.org/order"))
+	cfg.Option(PackagePaths(map[string]string{
+		"http://example.org/order":  "example.org/shop/order",
+		"http://example.org/common": "example.org/shop/common",
+	}))
+	packages, err := cfg.GenPackages("testdata/packages/order.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]string{
+		"example.org/shop/order": {
+			`package order`,
+			`"example.org/shop/common"`,
+			`Customer +common.Party`,
+			`Currency +\*common.Currency`,
+			`Code +common.T`,
+			`type T Order`,
+			`type xsdDate time.Time`,
+		},
+		"example.org/shop/common": {
+			`package common`,
+			`type Party struct`,
+			`type Currency string`,
+			`type xsdDate time.Time`,
+			`func _unmarshalTime`,
+		},
+	}
+	for importPath, patterns := range tests {
+		data := string(packages[importPath])
+		for _, pattern := range patterns {
+			if !grep(pattern, data) {
+				t.Errorf("package %s does not match %s", importPath, pattern)
+			}
+		}
+		t.Logf("%s\n", data)
+	}
+	if grep(`\(\*common.T\)`, string(packages["example.org/shop/order"])) {
+		t.Error("local type T is qualified with the package of common.T")
+	}
+	if len(packages) != len(tests) {
+		t.Errorf("generated %d packages, want %d", len(packages), len(tests))
+	}
+
+	cfg.Option(PackagePaths(map[string]string{
+		"http://example.org/order": "example.org/shop/order",
+	}))
+	if _, err := cfg.GenPackages("testdata/packages/order.xsd"); err == nil {
+		t.Error("expected error for a namespace without a package")
+	}
+}
+
+func TestImportPackage(t *testing.T) {
+	var cfg Config
+	cfg.Option(DefaultOptions...)
+	cfg.Option(LogOutput((*testLogger)(t)))
+	cfg.Option(FollowImports(true))
+	docs, err := cfg.ReadFiles("testdata/packages/order.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := cfg.Option(Namespaces("http://example.org/common"))
+	code, err := cfg.GenCode(docs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := code.Manifest()
+	types := manifest["http://example.org/common"]
+	if types["party"] != "Party" || types["currency"] != "Currency" {
+		t.Errorf("manifest does not list the common types: %v", manifest)
+	}
+	cfg.Option(prev)
+
+	cfg.Option(Namespaces("http://example.org/order"))
+	cfg.Option(ImportPackage("http://example.org/common", "example.org/shop/common", types))
+	code, err = cfg.GenCode(docs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := code.GenAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := gen.FormattedSource(file, "order.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := string(src)
+	for _, pattern := range []string{
+		`"example.org/shop/common"`,
+		`Customer +common.Party`,
+		`Currency +common.Currency`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	if grep(`type Party struct`, data) {
+		t.Error("imported type Party declared again")
+	}
+	t.Logf("%s\n", data)
+
+	cfg.Option(ImportPackage("http://example.org/common", "example.org/shop/common",
+		map[string]string{"currency": "Currency"}))
+	if _, err := cfg.GenCode(docs...); err == nil {
+		t.Error("expected error for a type missing from the manifest")
+	}
+}
+
+func TestMapType(t *testing.T) {
+	data := testGen(t, "http://example.org/ledger", "-validate",
+		"-map", "dateTime=cloud.google.com/go/civil.DateTime",
+		"-map", "{http://example.org/ledger}money=*math/big.Float",
+		"testdata/typemap.xsd")
+	for _, pattern := range []string{
+		`"cloud.google.com/go/civil"`,
+		`"math/big"`,
+		`Posted +civil.DateTime +`,
+		`Due +time.Time +`,
+		`Amount +\*big.Float +`,
+		`Credit +\*big.Float +`,
+		`Due +\*xsdDate +`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	for _, pattern := range []string{
+		`type Money `,
+		`type Credit `,
+		`xsdDateTime`,
+	} {
+		if grep(pattern, data) {
+			t.Errorf("generated code should not match %s", pattern)
+		}
+	}
+	t.Logf("%s\n", data)
+}
+
+func TestConfigFile(t *testing.T) {
+	data := testGen(t, "http://example.org/ledger", "-config", "testdata/config.json",
+		"testdata/typemap.xsd")
+	for _, pattern := range []string{
+		`package ledger`,
+		`type LedgerEntry struct`,
+		`Amount +\*big.Float +`,
+		`func \(t LedgerEntry\) Validate\(\) error`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	if grep(`Due `, data) {
+		t.Error("ignored element due is declared")
+	}
+	t.Logf("%s\n", data)
+
+	data = testGen(t, "http://example.org/ledger", "-config", "testdata/config.json",
+		"-validate=false", "testdata/typemap.xsd")
+	if grep(`Validate\(\) error`, data) {
+		t.Error("-validate=false does not override the configuration file")
+	}
+
+	file, err := ioutil.TempFile("", "xsdgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`{"validate": true}`)
+	file.Close()
+	if _, err := ReadConfigFile(file.Name()); err == nil {
+		t.Error("expected error for an unknown option")
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	data := testGen(t, "http://example.org/invoice", "-f",
+		"-catalog", "testdata/imports/catalog.xml", "testdata/imports/invoice.xsd")
+	for _, pattern := range []string{
+		`type Invoice struct`,
+		`BillTo +Party`,
+		`Due +float64`,
+	} {
+		if !grep(pattern, data) {
+			t.Errorf("generated code does not match %s", pattern)
+		}
+	}
+	t.Logf("%s\n", data)
 }